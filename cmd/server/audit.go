@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/db"
+)
+
+// actorOf names who made a request for the audit log: the user the reverse
+// proxy authenticated, else the client address it forwarded, else ours.
+// The headers are only read from the local proxy, which must set or unset
+// them itself (RequestHeader set/unset X-Remote-User and X-Forwarded-User
+// in the Apache vhost); a client's own copies would otherwise pass through.
+// Apache appends the real client to X-Forwarded-For, so its last entry is
+// the one to trust.
+func actorOf(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !loopback(r) {
+		return host
+	}
+	for _, h := range []string{"X-Remote-User", "X-Forwarded-User"} {
+		if v := r.Header.Get(h); v != "" {
+			return v
+		}
+	}
+	if v := r.Header.Get("X-Forwarded-For"); v != "" {
+		return strings.TrimSpace(v[strings.LastIndex(v, ",")+1:])
+	}
+	return host
+}
+
+// autosaveMerge is how long after an actor's last change to a workout an
+// autosave is folded into that change's audit event.
+const autosaveMerge = 5 * time.Minute
+
+// auditSave records what a save did to workoutID: create if it didn't exist
+// before (before == nil), edit if it was already completed, else save.
+func auditSave(ctx context.Context, q db.Querier, r *http.Request, workoutID int64, before json.RawMessage) error {
+	e, err := saveEvent(ctx, q, r, workoutID, before)
+	if err != nil {
+		return err
+	}
+	return db.RecordAudit(ctx, q, e)
+}
+
+// auditAutosave is auditSave for autosaves, merging runs of them.
+func auditAutosave(ctx context.Context, q db.Querier, r *http.Request, workoutID int64, before json.RawMessage) error {
+	e, err := saveEvent(ctx, q, r, workoutID, before)
+	if err != nil {
+		return err
+	}
+	return db.MergeAudit(ctx, q, e, autosaveMerge)
+}
+
+func saveEvent(ctx context.Context, q db.Querier, r *http.Request, workoutID int64, before json.RawMessage) (db.AuditEvent, error) {
+	after, err := db.Snapshot(ctx, q, workoutID)
+	if err != nil {
+		return db.AuditEvent{}, err
+	}
+	action := db.AuditSave
+	if before == nil {
+		action = db.AuditCreate
+	} else if completedIn(before) {
+		action = db.AuditEdit
+	}
+	return db.AuditEvent{WorkoutID: workoutID, Action: action, Actor: actorOf(r), Before: before, After: after}, nil
+}
+
+// completedIn reports whether a workout snapshot is of a completed workout.
+func completedIn(snap json.RawMessage) bool {
+	var w struct {
+		CompletedAt *string `json:"completed_at"`
+	}
+	return json.Unmarshal(snap, &w) == nil && w.CompletedAt != nil
+}
+
+// auditedExec runs one statement about workoutID ($1) in a transaction and
+// records action with the workout's state around it. It reports whether the
+// statement touched exactly one row; nothing is recorded when it didn't.
+func auditedExec(ctx context.Context, pool *pgxpool.Pool, r *http.Request, workoutID int64, action, sql string) (bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := db.Snapshot(ctx, tx, workoutID)
+	if err != nil {
+		return false, err
+	}
+	tag, err := tx.Exec(ctx, sql, workoutID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() != 1 {
+		return false, nil
+	}
+	after, err := db.Snapshot(ctx, tx, workoutID)
+	if err != nil {
+		return false, err
+	}
+	if err := db.RecordAudit(ctx, tx, db.AuditEvent{
+		WorkoutID: workoutID, Action: action, Actor: actorOf(r), Before: before, After: after,
+	}); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// historyRow is an audit event as shown on the session page.
+type historyRow struct {
+	At     string
+	Action string
+	Actor  string
+	Before string
+	After  string
+}
+
+func loadHistory(ctx context.Context, pool *pgxpool.Pool, workoutID int64) ([]historyRow, error) {
+	events, err := db.AuditEvents(ctx, pool, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	loc := loadLoc()
+	out := make([]historyRow, 0, len(events))
+	for _, e := range events {
+		out = append(out, historyRow{
+			At:     e.At.In(loc).Format("2006-01-02 15:04:05"),
+			Action: e.Action,
+			Actor:  e.Actor,
+			Before: indentJSON(e.Before),
+			After:  indentJSON(e.After),
+		})
+	}
+	return out, nil
+}
+
+func indentJSON(b json.RawMessage) string {
+	if b == nil {
+		return ""
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return string(b)
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return string(b)
+	}
+	return string(out)
+}
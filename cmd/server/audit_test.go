@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestActorOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"proxy user", "127.0.0.1:40000", map[string]string{"X-Remote-User": "ann", "X-Forwarded-For": "198.51.100.2"}, "ann"},
+		{"proxy forwarded user", "127.0.0.1:40000", map[string]string{"X-Forwarded-User": "bob"}, "bob"},
+		{"proxy client", "127.0.0.1:40000", map[string]string{"X-Forwarded-For": "198.51.100.2"}, "198.51.100.2"},
+		{"forged entry before the proxy's", "[::1]:40000", map[string]string{"X-Forwarded-For": "mallory, 198.51.100.2"}, "198.51.100.2"},
+		{"local tool", "127.0.0.1:40000", nil, "127.0.0.1"},
+		{"direct client can't claim a user", "198.51.100.9:40000", map[string]string{"X-Remote-User": "ann", "X-Forwarded-For": "10.0.0.1"}, "198.51.100.9"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/session/save", nil)
+		r.RemoteAddr = tt.remote
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+		if got := actorOf(r); got != tt.want {
+			t.Errorf("%s: actorOf = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"traininglog/internal/db"
+	"traininglog/internal/plan"
+)
+
+// errNoWorkout means a save or autosave named a workout that doesn't exist
+// (or is in the trash).
+var errNoWorkout = errors.New("no such workout")
+
+// errBadRef means an autosave's {id} is neither a workout id nor a client
+// UUID with a valid rotation day.
+var errBadRef = errors.New("bad workout reference")
+
+// txBeginner starts the transaction an autosave runs in; *pgxpool.Pool is one.
+type txBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// autosaveTarget is the workout an autosave request writes to.
+type autosaveTarget struct {
+	ID      int64
+	Day     int
+	Deload  bool // planned in a deload week
+	Created bool
+}
+
+// resolveAutosaveTarget locks the {id} workout. Before the form's first save
+// the page only knows its client UUID, so {id} may be that instead; the
+// workout is then created for rotation day "day" (and "deload") on first use.
+func resolveAutosaveTarget(ctx context.Context, tx pgx.Tx, r *http.Request) (autosaveTarget, error) {
+	var t autosaveTarget
+	ref := r.PathValue("id")
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil && id > 0 {
+		err := tx.QueryRow(ctx, `SELECT id, day_num, deload FROM workouts WHERE id=$1 AND deleted_at IS NULL FOR UPDATE`, id).Scan(&t.ID, &t.Day, &t.Deload)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return t, errNoWorkout
+		}
+		return t, err
+	}
+	if !validUUID(ref) {
+		return t, errBadRef
+	}
+	day, err := strconv.Atoi(r.PostFormValue("day"))
+	if err != nil || day < 1 || day > 12 {
+		return t, errBadRef
+	}
+	deload := r.PostFormValue("deload") != ""
+	// DO NOTHING + re-read lets two racing first keystrokes share one row
+	err = tx.QueryRow(ctx,
+		`INSERT INTO workouts(day_num, client_uuid, deload) VALUES ($1, $2::uuid, $3) ON CONFLICT (client_uuid) DO NOTHING RETURNING id`,
+		day, ref, deload).Scan(&t.ID)
+	switch {
+	case err == nil:
+		t.Day, t.Deload, t.Created = day, deload, true
+		return t, nil
+	case !errors.Is(err, pgx.ErrNoRows):
+		return t, err
+	}
+	st, ok, err := db.WorkoutByClientUUID(ctx, tx, ref)
+	if err != nil {
+		return t, err
+	}
+	if !ok || st.DeletedAt != nil {
+		return t, errNoWorkout
+	}
+	t.ID = st.ID
+	return t, tx.QueryRow(ctx, `SELECT day_num, deload FROM workouts WHERE id=$1`, st.ID).Scan(&t.Day, &t.Deload)
+}
+
+// autosaveWrite applies one changed value. A non-empty message rejects the
+// value; nothing is written then. Anything written to out (e.g. an
+// out-of-band fragment) is sent once the change is committed.
+type autosaveWrite func(ctx context.Context, tx pgx.Tx, r *http.Request, t autosaveTarget, out *bytes.Buffer) (msg string, err error)
+
+// handleAutosave wraps a single-value write: it resolves the workout, runs
+// write in a transaction, bumps the revision and records the change. The
+// response goes into the field's error slot, empty on success or the
+// message with a 422; a workout moved to the trash meanwhile gets a 409.
+func handleAutosave(pool txBeginner, write autosaveWrite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		t, err := resolveAutosaveTarget(ctx, tx, r)
+		if errors.Is(err, errBadRef) {
+			http.NotFound(w, r)
+			return
+		}
+		if errors.Is(err, errNoWorkout) {
+			http.Error(w, goneMessage, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		var before json.RawMessage
+		if !t.Created {
+			if before, err = db.Snapshot(ctx, tx, t.ID); err != nil {
+				http.Error(w, "db error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		var out bytes.Buffer
+		msg, err := write(ctx, tx, r, t, &out)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if msg != "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(msg))
+			return
+		}
+		if err := db.LinkExercises(ctx, tx, t.ID); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if err := syncGroups(ctx, tx, t.ID, t.Day, t.Deload); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+
+		rev, err := touchWorkout(ctx, tx, t.ID)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if err := auditAutosave(ctx, tx, r, t.ID, before); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("X-Revision", strconv.Itoa(rev))
+		if t.Created {
+			fmt.Fprintf(w, `<input type="hidden" id="workout_id" name="workout_id" value="%d" hx-swap-oob="outerHTML">`, t.ID)
+		}
+		out.WriteTo(w)
+	}
+}
+
+// PUT /sessions/{id}/items/{label}/sets/{n}  value=<reps>, empty clears the set;
+// planned=<plan label> when label was swapped in for it
+func autosaveSet(ctx context.Context, tx pgx.Tx, r *http.Request, t autosaveTarget, _ *bytes.Buffer) (string, error) {
+	label := r.PathValue("label")
+	planned := r.PostFormValue("planned")
+	if planned == "" {
+		planned = label
+	}
+	if msg := checkLabel(label); msg != "" {
+		return msg, nil
+	}
+	if msg := checkSwap(t.Day, planned, label); msg != "" {
+		return msg, nil
+	}
+	maxSets, maxValue := setLimits(t.Day, t.Deload, planned)
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil || n < 1 || n > maxSets {
+		return fmt.Sprintf("set must be 1–%d", maxSets), nil
+	}
+	var v int
+	vStr := strings.TrimSpace(r.PostFormValue("value"))
+	if vStr != "" {
+		var msg string
+		if v, msg = checkSetValue(vStr, maxValue); msg != "" {
+			return fmt.Sprintf("set %d: %s", n, msg), nil
+		}
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM workout_items WHERE workout_id=$1 AND COALESCE(planned_label, label)=$2 AND kind='sets' AND set_index=$3`,
+		t.ID, planned, n); err != nil {
+		return "", err
+	}
+	if vStr == "" {
+		return "", nil
+	}
+	// the first set typed in starts the session, unless a start was entered
+	if _, err := tx.Exec(ctx, `UPDATE workouts SET started_at = COALESCE(started_at, now()) WHERE id=$1`, t.ID); err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO workout_items(workout_id,kind,label,set_index,value_int,planned_label) VALUES ($1,'sets',$2,$3,$4,NULLIF($5,$2))`,
+		t.ID, label, n, v, planned)
+	return "", err
+}
+
+// PUT /sessions/{id}/items/{label}/check  checked=1 or empty
+func autosaveCheck(ctx context.Context, tx pgx.Tx, r *http.Request, t autosaveTarget, _ *bytes.Buffer) (string, error) {
+	label := r.PathValue("label")
+	if msg := checkLabel(label); msg != "" {
+		return msg, nil
+	}
+	if pi, ok := plan.Find(t.Day, label); !ok || pi.Kind != "check" {
+		return "not on this day's plan", nil
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM workout_items WHERE workout_id=$1 AND label=$2 AND kind='check'`, t.ID, label); err != nil {
+		return "", err
+	}
+	_, err := tx.Exec(ctx,
+		`INSERT INTO workout_items(workout_id,kind,label,checked) VALUES ($1,'check',$2,$3)`,
+		t.ID, label, r.PostFormValue("checked") != "")
+	return "", err
+}
+
+// PUT /sessions/{id}/items/{label}/swap  value=<substitute>, empty goes back
+// to the planned exercise. {label} is the plan's label. Sets already logged
+// move to the new exercise, and the item's block is re-rendered from the
+// posted form for its new name and history.
+func autosaveSwap(ctx context.Context, tx pgx.Tx, r *http.Request, t autosaveTarget, out *bytes.Buffer) (string, error) {
+	planned := r.PathValue("label")
+	pi, ok := plan.Find(t.Day, planned)
+	if !ok || pi.Kind != "sets" {
+		return "not a planned exercise", nil
+	}
+	performed := r.PostFormValue("value")
+	if performed == "" {
+		performed = planned
+	}
+	if msg := checkSwap(t.Day, planned, performed); msg != "" {
+		return msg, nil
+	}
+	if _, err := tx.Exec(ctx, `
+UPDATE workout_items SET label=$3, planned_label=NULLIF($2,$3), exercise_id=NULL, planned_exercise_id=NULL
+WHERE workout_id=$1 AND kind='sets' AND COALESCE(planned_label, label)=$2`,
+		t.ID, planned, performed); err != nil {
+		return "", err
+	}
+
+	b, e := postedBlock(r, t.Day, planned)
+	if b == nil {
+		return "", nil
+	}
+	e.Label = performed
+	b.relabel()
+	b.OOB = true
+	return "", renderBlock(ctx, tx, out, *b)
+}
+
+// PUT /sessions/{id}/items/{label}/sets  value=<set count>; sets past it are
+// dropped. {label} is the plan's label, or an added exercise's name. The
+// item's block is re-rendered from the posted form with the new count.
+func autosaveSetCount(ctx context.Context, tx pgx.Tx, r *http.Request, t autosaveTarget, out *bytes.Buffer) (string, error) {
+	planned := r.PathValue("label")
+	if msg := checkLabel(planned); msg != "" {
+		return msg, nil
+	}
+	maxSets, _ := setLimits(t.Day, t.Deload, planned)
+	n, err := strconv.Atoi(r.PostFormValue("value"))
+	if err != nil || n < 1 || n > maxSets {
+		return fmt.Sprintf("1–%d sets", maxSets), nil
+	}
+	b, e := postedBlock(r, t.Day, planned)
+	if b == nil {
+		return "not on this form", nil
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM workout_items WHERE workout_id=$1 AND COALESCE(planned_label, label)=$2 AND kind='sets' AND set_index>$3`,
+		t.ID, planned, n); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM workout_rests WHERE workout_id=$1 AND label=$2 AND set_index>$3`, t.ID, e.Label, n); err != nil {
+		return "", err
+	}
+	e.Sets = n
+	b.OOB = true
+	return "", renderBlock(ctx, tx, out, *b)
+}
+
+// POST /sessions/{id}/rests  label, set_index, planned_secs, actual_secs;
+// planned=<plan label> when label was swapped in for it
+func autosaveRest(ctx context.Context, tx pgx.Tx, r *http.Request, t autosaveTarget, _ *bytes.Buffer) (string, error) {
+	label := r.PostFormValue("label")
+	planned := r.PostFormValue("planned")
+	if planned == "" {
+		planned = label
+	}
+	maxSets, _ := setLimits(t.Day, t.Deload, planned)
+	n, err := strconv.Atoi(r.PostFormValue("set_index"))
+	if label == "" || err != nil || n < 1 || n > maxSets {
+		return "invalid set", nil
+	}
+	plannedSecs, err := strconv.Atoi(r.PostFormValue("planned_secs"))
+	if err != nil || plannedSecs < 0 || plannedSecs > maxRestSecs {
+		return "invalid planned rest", nil
+	}
+	actual, err := strconv.Atoi(r.PostFormValue("actual_secs"))
+	if err != nil || actual < 0 || actual > maxRecordedRest {
+		return "invalid rest", nil
+	}
+	return "", db.RecordRest(ctx, tx, t.ID, label, n, plannedSecs, actual)
+}
+
+// PUT /sessions/{id}/meta/{field}  value=<new value>, empty clears it
+func autosaveMeta(ctx context.Context, tx pgx.Tx, r *http.Request, t autosaveTarget, _ *bytes.Buffer) (string, error) {
+	s := r.PostFormValue("value")
+	var col string
+	var val any
+	switch r.PathValue("field") {
+	case "session_date":
+		col = "session_date"
+		if s != "" {
+			d, msg := checkSessionDate(s)
+			if msg != "" {
+				return msg, nil
+			}
+			val = *d
+		}
+	case "body_weight_kg":
+		col = "body_weight_kg"
+		if s != "" {
+			bw, msg := checkBodyWeight(s)
+			if msg != "" {
+				return msg, nil
+			}
+			val = *bw
+		}
+	case "started_at", "ended_at":
+		col = r.PathValue("field")
+		if s != "" {
+			at, msg := checkClock(s, loadLoc())
+			if msg != "" {
+				return msg, nil
+			}
+			// check against the other end as stored
+			var st, et *time.Time
+			if err := tx.QueryRow(ctx, `SELECT started_at, ended_at FROM workouts WHERE id=$1`, t.ID).Scan(&st, &et); err != nil {
+				return "", err
+			}
+			if col == "started_at" {
+				st = at
+			} else {
+				et = at
+			}
+			if msg := checkSpan(st, et); msg != "" {
+				return msg, nil
+			}
+			val = *at
+		}
+	case "notes":
+		col = "notes"
+		n, msg := checkNotes(s)
+		if msg != "" {
+			return msg, nil
+		}
+		if n != "" {
+			val = n
+		}
+	default:
+		return "unknown field", nil
+	}
+	_, err := tx.Exec(ctx, `UPDATE workouts SET `+col+`=$2 WHERE id=$1`, t.ID, val)
+	return "", err
+}
+
+// touchWorkout bumps the workout's revision after a write and returns it.
+func touchWorkout(ctx context.Context, tx pgx.Tx, workoutID int64) (int, error) {
+	var rev int
+	err := tx.QueryRow(ctx,
+		`UPDATE workouts SET revision = revision + 1 WHERE id=$1 RETURNING revision`,
+		workoutID).Scan(&rev)
+	return rev, err
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeDB stands in for the pool in handler tests. QueryRow answers from
+// rows, the first entry whose match the SQL contains; a nil vals is "no
+// rows". Exec only records the statement; Query finds nothing.
+type fakeDB struct {
+	rows      []fakeResult
+	execs     []fakeExec
+	committed bool
+}
+
+type fakeResult struct {
+	match string
+	vals  []any
+}
+
+type fakeExec struct {
+	sql  string
+	args []any
+}
+
+func (f *fakeDB) Begin(context.Context) (pgx.Tx, error) { return fakeTx{db: f}, nil }
+
+// exec returns the recorded statement containing match, if any.
+func (f *fakeDB) exec(match string) (fakeExec, bool) {
+	for _, e := range f.execs {
+		if strings.Contains(e.sql, match) {
+			return e, true
+		}
+	}
+	return fakeExec{}, false
+}
+
+// fakeTx implements the pgx.Tx methods handlers use; others panic.
+type fakeTx struct {
+	pgx.Tx
+	db *fakeDB
+}
+
+func (tx fakeTx) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tx.db.execs = append(tx.db.execs, fakeExec{sql, args})
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (tx fakeTx) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	for _, r := range tx.db.rows {
+		if strings.Contains(sql, r.match) {
+			return fakeRow{vals: r.vals, sql: sql}
+		}
+	}
+	return fakeRow{err: fmt.Errorf("unexpected query %q", sql)}
+}
+
+func (tx fakeTx) Query(context.Context, string, ...any) (pgx.Rows, error) { return &noRows{}, nil }
+func (tx fakeTx) Commit(context.Context) error                            { tx.db.committed = true; return nil }
+func (tx fakeTx) Rollback(context.Context) error                          { return nil }
+
+type fakeRow struct {
+	vals []any
+	sql  string
+	err  error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.vals == nil {
+		return pgx.ErrNoRows
+	}
+	if len(dest) != len(r.vals) {
+		return fmt.Errorf("scan of %d values into %d for %q", len(r.vals), len(dest), r.sql)
+	}
+	for i, v := range r.vals {
+		d := reflect.ValueOf(dest[i]).Elem()
+		if v == nil {
+			d.Set(reflect.Zero(d.Type()))
+			continue
+		}
+		d.Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+type noRows struct{ pgx.Rows }
+
+func (*noRows) Next() bool { return false }
+func (*noRows) Close()     {}
+func (*noRows) Err() error { return nil }
+
+// liveWorkout answers the autosave queries for live workout 7 on day 1,
+// now at revision 5.
+func liveWorkout() []fakeResult {
+	return []fakeResult{
+		{"deleted_at IS NULL FOR UPDATE", []any{int64(7), 1, false}},
+		{"to_jsonb(w)", []any{json.RawMessage(`{"day_num":1}`)}},
+		{"RETURNING revision", []any{5}},
+	}
+}
+
+func autosaveMux(f *fakeDB) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /sessions/{id}/items/{label}/sets/{n}", handleAutosave(f, autosaveSet))
+	mux.HandleFunc("PUT /sessions/{id}/items/{label}/check", handleAutosave(f, autosaveCheck))
+	mux.HandleFunc("POST /sessions/{id}/rests", handleAutosave(f, autosaveRest))
+	return mux
+}
+
+func autosave(f *fakeDB, method, path string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.RemoteAddr = "127.0.0.1:40000"
+	w := httptest.NewRecorder()
+	autosaveMux(f).ServeHTTP(w, r)
+	return w
+}
+
+const pushups = "/sessions/7/items/inc%202%20pushups/sets/"
+
+func TestAutosaveSet(t *testing.T) {
+	f := &fakeDB{rows: liveWorkout()}
+	w := autosave(f, http.MethodPut, pushups+"2", url.Values{"value": {"12"}})
+	if w.Code != http.StatusOK || w.Header().Get("X-Revision") != "5" {
+		t.Fatalf("status %d revision %q: %s", w.Code, w.Header().Get("X-Revision"), w.Body)
+	}
+	ins, ok := f.exec("INSERT INTO workout_items")
+	if !ok || !reflect.DeepEqual(ins.args, []any{int64(7), "inc 2 pushups", 2, 12, "inc 2 pushups"}) {
+		t.Errorf("insert %v, want the set written", ins.args)
+	}
+	if _, ok := f.exec("started_at = COALESCE(started_at, now())"); !ok {
+		t.Error("a logged set didn't start the session clock")
+	}
+	if !f.committed {
+		t.Error("not committed")
+	}
+}
+
+func TestAutosaveSetCleared(t *testing.T) {
+	f := &fakeDB{rows: liveWorkout()}
+	w := autosave(f, http.MethodPut, pushups+"2", url.Values{"value": {""}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if _, ok := f.exec("DELETE FROM workout_items"); !ok {
+		t.Error("cleared set not deleted")
+	}
+	for _, sql := range []string{"INSERT INTO workout_items", "started_at"} {
+		if _, ok := f.exec(sql); ok {
+			t.Errorf("clearing a set ran %q", sql)
+		}
+	}
+}
+
+func TestAutosaveRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		form   url.Values
+		rows   []fakeResult
+		status int
+		body   string
+	}{
+		{"value too big", http.MethodPut, pushups + "1", url.Values{"value": {"61"}}, liveWorkout(),
+			http.StatusUnprocessableEntity, "set 1: over 60, typo?"},
+		{"not a number", http.MethodPut, pushups + "1", url.Values{"value": {"ten"}}, liveWorkout(),
+			http.StatusUnprocessableEntity, "set 1: whole numbers only"},
+		{"set past the extras", http.MethodPut, pushups + "8", url.Values{"value": {"10"}}, liveWorkout(),
+			http.StatusUnprocessableEntity, "set must be 1–7"},
+		{"swap the plan doesn't offer", http.MethodPut, "/sessions/7/items/bw%20squats/sets/1",
+			url.Values{"value": {"10"}, "planned": {"inc 2 pushups"}}, liveWorkout(),
+			http.StatusUnprocessableEntity, `"bw squats" can't replace "inc 2 pushups"`},
+		{"check not on the plan", http.MethodPut, "/sessions/7/items/green%20rows/check", url.Values{"checked": {"1"}}, liveWorkout(),
+			http.StatusUnprocessableEntity, "not on this day's plan"},
+		{"check on another day's plan", http.MethodPut, "/sessions/7/items/cook/check", url.Values{"checked": {"1"}}, liveWorkout(),
+			http.StatusUnprocessableEntity, "not on this day's plan"},
+		{"trashed workout", http.MethodPut, pushups + "1", url.Values{"value": {"10"}},
+			[]fakeResult{{"deleted_at IS NULL FOR UPDATE", nil}},
+			http.StatusConflict, goneMessage},
+		{"trashed workout by client uuid", http.MethodPut, "/sessions/0b6c1c8e-4f7e-4d3b-9c59-2a8f3b6b1d11/items/foam%20roll/check",
+			url.Values{"checked": {"1"}, "day": {"1"}},
+			[]fakeResult{
+				{"ON CONFLICT (client_uuid) DO NOTHING", nil},
+				{"WHERE client_uuid", []any{int64(7), 3, (*string)(nil), (*time.Time)(nil), &time.Time{}}},
+			},
+			http.StatusConflict, goneMessage},
+		{"not a workout reference", http.MethodPut, "/sessions/nope/items/foam%20roll/check", url.Values{"checked": {"1"}}, nil,
+			http.StatusNotFound, "404 page not found"},
+		{"client uuid without a day", http.MethodPut, "/sessions/0b6c1c8e-4f7e-4d3b-9c59-2a8f3b6b1d11/items/foam%20roll/check",
+			url.Values{"checked": {"1"}}, nil,
+			http.StatusNotFound, "404 page not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeDB{rows: tt.rows}
+			w := autosave(f, tt.method, tt.path, tt.form)
+			if w.Code != tt.status || strings.TrimSpace(w.Body.String()) != tt.body {
+				t.Errorf("got %d %q, want %d %q", w.Code, w.Body, tt.status, tt.body)
+			}
+			if len(f.execs) > 0 || f.committed {
+				t.Errorf("rejected autosave wrote %d statement(s), committed %v", len(f.execs), f.committed)
+			}
+		})
+	}
+}
+
+func TestAutosaveCheck(t *testing.T) {
+	f := &fakeDB{rows: liveWorkout()}
+	w := autosave(f, http.MethodPut, "/sessions/7/items/foam%20roll/check", url.Values{"checked": {"1"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	ins, ok := f.exec("INSERT INTO workout_items")
+	if !ok || !slices.Equal(ins.args, []any{int64(7), "foam roll", true}) {
+		t.Errorf("insert %v, want foam roll ticked", ins.args)
+	}
+}
+
+func TestAutosaveCreatesByClientUUID(t *testing.T) {
+	f := &fakeDB{rows: []fakeResult{
+		{"ON CONFLICT (client_uuid) DO NOTHING", []any{int64(9)}},
+		{"RETURNING revision", []any{1}},
+		{"to_jsonb(w)", []any{json.RawMessage(`{"day_num":1}`)}},
+		{"FROM audit_events", nil},
+	}}
+	w := autosave(f, http.MethodPut, "/sessions/0b6c1c8e-4f7e-4d3b-9c59-2a8f3b6b1d11/items/foam%20roll/check",
+		url.Values{"checked": {"1"}, "day": {"1"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="workout_id" value="9"`) {
+		t.Fatalf("status %d %q, want the new workout id swapped in", w.Code, w.Body)
+	}
+	if _, ok := f.exec("INSERT INTO audit_events"); !ok {
+		t.Error("creation not audited")
+	}
+}
+
+func TestAutosaveRestSwapped(t *testing.T) {
+	rest := func(set string) url.Values {
+		return url.Values{"label": {"knee pushups"}, "planned": {"inc 2 pushups"}, "set_index": {set}, "planned_secs": {"60"}, "actual_secs": {"75"}}
+	}
+	// the planned pushups allow 4 sets and 3 extra, not the unplanned 10
+	f := &fakeDB{rows: liveWorkout()}
+	if w := autosave(f, http.MethodPost, "/sessions/7/rests", rest("8")); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("set 8: status %d, want 422", w.Code)
+	}
+	f = &fakeDB{rows: liveWorkout()}
+	if w := autosave(f, http.MethodPost, "/sessions/7/rests", rest("7")); w.Code != http.StatusOK {
+		t.Fatalf("set 7: status %d: %s", w.Code, w.Body)
+	}
+	ins, ok := f.exec("INSERT INTO workout_rests")
+	if !ok || !slices.Equal(ins.args, []any{int64(7), "knee pushups", 7, 60, 75}) {
+		t.Errorf("rest %v, want it recorded for the performed exercise", ins.args)
+	}
+}
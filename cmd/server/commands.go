@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/db"
+)
+
+// runCommand dispatches CLI subcommands; without one, main starts the server.
+func runCommand(ctx context.Context, pool *pgxpool.Pool, name string, args []string) error {
+	switch name {
+	case "backup":
+		return runBackup(ctx, pool, args)
+	case "restore":
+		return runRestore(ctx, pool, args)
+	case "exercises":
+		return runExercises(ctx, pool, args)
+	case "report":
+		return runReport(ctx, pool, args)
+	case "remind":
+		return runRemind(ctx, pool, args)
+	default:
+		return fmt.Errorf("unknown command %q (want backup, restore, exercises, report or remind)", name)
+	}
+}
+
+// traininglog backup [file]  — writes the archive to file, or stdout if omitted.
+func runBackup(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	a, err := db.Backup(ctx, pool)
+	if err != nil {
+		return err
+	}
+	var out io.Writer = os.Stdout
+	if len(args) > 0 {
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	return json.NewEncoder(out).Encode(a)
+}
+
+// traininglog restore <file>  — loads an archive into an empty database.
+func runRestore(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: traininglog restore <file>")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var a db.Archive
+	if err := json.NewDecoder(f).Decode(&a); err != nil {
+		return fmt.Errorf("read archive: %w", err)
+	}
+	return db.Restore(ctx, pool, &a)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/db"
+	"traininglog/internal/plan"
+)
+
+// workoutDate is the local calendar day a workout counts towards: the
+// session date picked on the form, else the day it was completed. pgx returns
+// DATE columns as UTC midnight, so session_date is formatted without moving
+// it into loc. Drafts with neither return "".
+func workoutDate(sd, ct *time.Time, loc *time.Location) string {
+	if sd != nil {
+		return sd.Format("2006-01-02")
+	}
+	if ct != nil {
+		return ct.In(loc).Format("2006-01-02")
+	}
+	return ""
+}
+
+// maxSessionLength caps a recorded start-to-end span; anything longer is a
+// forgotten "end" rather than a session.
+const maxSessionLength = 12 * time.Hour
+
+// sessionDuration is the time from start to end, when both were recorded
+// and make a plausible session.
+func sessionDuration(st, et *time.Time) (time.Duration, bool) {
+	if st == nil || et == nil || et.Before(*st) || et.Sub(*st) > maxSessionLength {
+		return 0, false
+	}
+	return et.Sub(*st).Round(time.Minute), true
+}
+
+// fmtDuration renders d as "45m" or "1h 05m".
+func fmtDuration(d time.Duration) string {
+	m := int(d.Round(time.Minute) / time.Minute)
+	if m < 60 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh %02dm", m/60, m%60)
+}
+
+// durationText is fmtDuration for an optional start/end pair, "" if unknown.
+func durationText(st, et *time.Time) string {
+	if d, ok := sessionDuration(st, et); ok {
+		return fmtDuration(d)
+	}
+	return ""
+}
+
+// loadBlockStart reads BLOCK_START (YYYY-MM-DD), the day the first
+// training block began. Unset, blocks count from the first workout.
+func loadBlockStart(loc *time.Location) time.Time {
+	t, err := time.ParseInLocation("2006-01-02", os.Getenv("BLOCK_START"), loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// nextSession is the rotation day due next and the block phase of today.
+func nextSession(ctx context.Context, pool *pgxpool.Pool) (db.Next, plan.Phase) {
+	loc := loadLoc()
+	n := db.NextDay(ctx, pool, loadBlockStart(loc), time.Now().In(loc))
+	return n, plan.Block.PhaseOf(n.Week)
+}
+
+// completedByDay buckets completed workouts whose workoutDate falls in
+// [start, end), both local midnights, keyed by "2006-01-02".
+func completedByDay(ctx context.Context, pool *pgxpool.Pool, loc *time.Location, start, end time.Time) (map[string][]CellSession, error) {
+	rows, err := pool.Query(ctx,
+		`SELECT id, day_num, session_date, completed_at, started_at, ended_at, body_weight_kg
+		FROM workouts
+		WHERE completed_at IS NOT NULL
+			AND deleted_at IS NULL
+			AND (
+				(session_date IS NOT NULL AND session_date >= $1::date AND session_date < $2::date)
+			OR (session_date IS NULL   AND completed_at >= $3 AND completed_at < $4)
+				)
+		ORDER BY completed_at`,
+		start.Format("2006-01-02"), end.Format("2006-01-02"), start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byDay := map[string][]CellSession{}
+	for rows.Next() {
+		var id int64
+		var day int
+		var sd *time.Time
+		var ct time.Time
+		var st, et *time.Time
+		var bw *float64
+		if err := rows.Scan(&id, &day, &sd, &ct, &st, &et, &bw); err != nil {
+			return nil, err
+		}
+		key := workoutDate(sd, &ct, loc)
+		cs := CellSession{ID: id, DayNum: day, Type: plan.DayType(day), BodyWeight: bw}
+		if d, ok := sessionDuration(st, et); ok {
+			cs.Duration = d
+		}
+		byDay[key] = append(byDay[key], cs)
+	}
+	return byDay, rows.Err()
+}
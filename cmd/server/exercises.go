@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/db"
+	"traininglog/internal/plan"
+)
+
+// setupExercises seeds the library from plan.Library and links any sets
+// that aren't tied to an exercise yet (everything logged before the library
+// existed, or restored from an older backup).
+func setupExercises(ctx context.Context, pool *pgxpool.Pool) error {
+	lib := make([]db.Exercise, 0, len(plan.Library))
+	for _, e := range plan.Library {
+		lib = append(lib, db.Exercise{
+			Slug: e.Slug, Name: e.Name, Aliases: e.Aliases,
+			Primary: e.Primary, Secondary: e.Secondary,
+			Equipment: e.Equipment, Unit: e.Unit,
+		})
+	}
+	if err := db.SeedExercises(ctx, pool, lib); err != nil {
+		return err
+	}
+	return db.LinkExercises(ctx, pool, 0)
+}
+
+// traininglog exercises list
+// traininglog exercises rename <slug> <new name>
+// traininglog exercises merge <from-slug> <into-slug>
+// traininglog exercises describe <slug> [primary=a,b] [secondary=c] [equipment=e] [unit=reps|secs]
+//
+// describe fills in exercises made up from a name typed into a session,
+// which start with no muscles; fields not given are left as they are.
+func runExercises(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	const usage = "usage: traininglog exercises list | rename <slug> <name> | merge <from> <into> | describe <slug> [primary=a,b] [secondary=c] [equipment=e] [unit=reps|secs]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	if err := setupExercises(ctx, pool); err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		es, err := db.Exercises(ctx, pool)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "SLUG\tNAME\tALIASES\tMUSCLES\tEQUIPMENT\tUNIT")
+		for _, e := range es {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Slug, e.Name,
+				strings.Join(e.Aliases, ", "), strings.Join(e.Primary, ", "), e.Equipment, e.Unit)
+		}
+		return tw.Flush()
+	case args[0] == "rename" && len(args) == 3:
+		return db.RenameExercise(ctx, pool, args[1], strings.TrimSpace(args[2]))
+	case args[0] == "merge" && len(args) == 3:
+		return db.MergeExercises(ctx, pool, args[1], args[2])
+	case args[0] == "describe" && len(args) >= 3:
+		return describeExercise(ctx, pool, args[1], args[2:])
+	default:
+		return errors.New(usage)
+	}
+}
+
+// describeExercise applies key=value fields to the exercise with slug.
+func describeExercise(ctx context.Context, pool *pgxpool.Pool, slug string, fields []string) error {
+	es, err := db.Exercises(ctx, pool)
+	if err != nil {
+		return err
+	}
+	var e *db.Exercise
+	for i := range es {
+		if es[i].Slug == slug {
+			e = &es[i]
+		}
+	}
+	if e == nil {
+		return fmt.Errorf("%w: %s", db.ErrNoExercise, slug)
+	}
+	for _, f := range fields {
+		k, v, ok := strings.Cut(f, "=")
+		v = strings.TrimSpace(v)
+		switch {
+		case !ok:
+			return fmt.Errorf("bad field %q, want key=value", f)
+		case k == "primary":
+			e.Primary = splitList(v)
+		case k == "secondary":
+			e.Secondary = splitList(v)
+		case k == "equipment":
+			e.Equipment = v
+		case k == "unit":
+			e.Unit = v
+		default:
+			return fmt.Errorf("unknown field %q (want primary, secondary, equipment or unit)", k)
+		}
+	}
+	return db.DescribeExercise(ctx, pool, *e)
+}
+
+// splitList splits "chest, triceps" into its non-empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
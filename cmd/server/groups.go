@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/db"
+	"traininglog/internal/plan"
+)
+
+// planFor is rotation day day as a workout stored with deload was planned.
+func planFor(day int, deload bool) []plan.Item {
+	return plan.DayIn(day, plan.Phase{Deload: deload})
+}
+
+// syncGroups refreshes how a workout's sets are grouped on rotation day
+// day, planned in a deload week or not: superset tags, and the round tags
+// and counts of every circuit. Call it after writing a workout's items.
+func syncGroups(ctx context.Context, q db.Querier, workoutID int64, day int, deload bool) error {
+	items := planFor(day, deload)
+	supersets := map[string][]string{}
+	var names []string
+	for _, it := range items {
+		if it.Kind != "sets" || it.Superset == "" {
+			continue
+		}
+		if _, ok := supersets[it.Superset]; !ok {
+			names = append(names, it.Superset)
+		}
+		supersets[it.Superset] = append(supersets[it.Superset], it.Label)
+	}
+	for _, name := range names {
+		if err := db.TagSuperset(ctx, q, workoutID, name, supersets[name]); err != nil {
+			return err
+		}
+	}
+	for _, c := range plan.Circuits(items) {
+		h := items[c.Heading]
+		members := make([]string, 0, len(c.Members))
+		for _, m := range c.Members {
+			members = append(members, items[m].Label)
+		}
+		cr := db.CircuitRounds{Label: h.Label, RoundsMin: h.RoundsMin, RoundsMax: h.RoundsMax}
+		if err := db.SyncCircuit(ctx, q, workoutID, cr, members); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillGroups runs syncGroups over workouts saved before grouping was
+// tracked: those of a day with circuits but no circuit rows yet, or with
+// untagged sets of an exercise their rotation day puts in a superset. Only
+// the day's own plan counts, so nothing matches again once synced.
+func backfillGroups(ctx context.Context, pool *pgxpool.Pool) error {
+	var circuitDays, days []int
+	var labels []string
+	for n := 1; n <= 12; n++ {
+		if len(plan.Circuits(plan.Day(n))) > 0 {
+			circuitDays = append(circuitDays, n)
+		}
+		for _, it := range plan.Day(n) {
+			if it.Kind == "sets" && it.Superset != "" {
+				days = append(days, n)
+				labels = append(labels, it.Label)
+			}
+		}
+	}
+	rows, err := pool.Query(ctx, `
+SELECT w.id, w.day_num, w.deload FROM workouts w
+WHERE (w.day_num = ANY($3)
+       AND EXISTS (SELECT 1 FROM workout_items wi WHERE wi.workout_id = w.id AND wi.kind = 'sets')
+       AND NOT EXISTS (SELECT 1 FROM workout_circuits wc WHERE wc.workout_id = w.id))
+   OR EXISTS (SELECT 1 FROM workout_items wi
+              JOIN unnest($1::int[], $2::text[]) AS s(day_num, label)
+                ON s.day_num = w.day_num AND s.label = COALESCE(wi.planned_label, wi.label)
+              WHERE wi.workout_id = w.id AND wi.kind = 'sets' AND wi.superset IS NULL)`,
+		days, labels, circuitDays)
+	if err != nil {
+		return err
+	}
+	type todo struct {
+		id     int64
+		day    int
+		deload bool
+	}
+	var ws []todo
+	for rows.Next() {
+		var t todo
+		if err := rows.Scan(&t.id, &t.day, &t.deload); err != nil {
+			rows.Close()
+			return err
+		}
+		ws = append(ws, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, t := range ws {
+		if err := syncGroups(ctx, pool, t.id, t.day, t.deload); err != nil {
+			return fmt.Errorf("workout %d: %w", t.id, err)
+		}
+	}
+	return nil
+}
+
+// formEntry is a plan item with the index its inputs are named by.
+type formEntry struct {
+	Index     int
+	plan.Item          // Label is the exercise performed
+	Planned   string   // the plan's label; differs from Label after a swap
+	Swaps     []string // exercises the plan allows in place of Planned
+	Added     bool     // not in the plan; added during the session
+	MaxSets   int
+	Values    map[int]string
+	Prev      []int // latest logged sets of Label
+	Rest      int   // seconds to rest after a set
+}
+
+// Value is what set n already holds when the form is re-rendered.
+func (e formEntry) Value(n int) string { return e.Values[n] }
+
+// More and Fewer are the set counts the add and remove set buttons ask for.
+func (e formEntry) More() int  { return e.Sets + 1 }
+func (e formEntry) Fewer() int { return e.Sets - 1 }
+
+// formRow is one round of a circuit, or one set of a superset.
+type formRow struct {
+	N        int
+	Optional bool // beyond the planned minimum
+}
+
+// formBlock is one piece of the session form: a single item, a circuit
+// heading whose exercises are laid out round by round, or a superset whose
+// exercises alternate set by set. A superset has no item of its own; its
+// Label names the exercises and its Index is its first exercise's.
+type formBlock struct {
+	formEntry
+	Superset string
+	Members  []formEntry // circuit or superset exercises
+	RowName  string      // "Round" or "Set"
+	Rows     []formRow
+	Interval int  // EMOM seconds of a circuit
+	OOB      bool // rendered as an out-of-band swap
+}
+
+// entries is the block's own entry followed by its members'.
+func (b *formBlock) entries() []*formEntry {
+	out := []*formEntry{&b.formEntry}
+	for i := range b.Members {
+		out = append(out, &b.Members[i])
+	}
+	return out
+}
+
+// relabel names a superset after its (possibly swapped) exercises.
+func (b *formBlock) relabel() {
+	if b.Superset == "" {
+		return
+	}
+	labels := make([]string, 0, len(b.Members))
+	for _, m := range b.Members {
+		labels = append(labels, m.Label)
+	}
+	b.Label = strings.Join(labels, " + ")
+}
+
+// formBlocks groups a day's items for the session form.
+func formBlocks(items []plan.Item, ts timerSettings) []formBlock {
+	entry := func(i int) formEntry {
+		e := formEntry{Index: i, Item: items[i], Planned: items[i].Label, MaxSets: items[i].Sets + plan.MaxExtraSets, Rest: ts.Rest(items[i])}
+		for _, s := range plan.Substitutes(items[i]) {
+			e.Swaps = append(e.Swaps, s.Name)
+		}
+		return e
+	}
+	circuits := map[int]plan.Circuit{}
+	member := map[int]bool{}
+	for _, c := range plan.Circuits(items) {
+		circuits[c.Heading] = c
+		for _, m := range c.Members {
+			member[m] = true
+		}
+	}
+
+	var out []formBlock
+	for i := 0; i < len(items); i++ {
+		it := items[i]
+		switch c, ok := circuits[i]; {
+		case member[i]:
+			continue
+		case ok:
+			b := formBlock{formEntry: entry(i), RowName: "Round", Interval: ts.Interval(it)}
+			for _, m := range c.Members {
+				b.Members = append(b.Members, entry(m))
+			}
+			for r := 1; r <= it.RoundsMax; r++ {
+				b.Rows = append(b.Rows, formRow{N: r, Optional: r > it.RoundsMin})
+			}
+			out = append(out, b)
+		case it.Kind == "sets" && it.Superset != "":
+			b := formBlock{formEntry: formEntry{Index: i}, Superset: it.Superset, RowName: "Set"}
+			sets := 0
+			for ; i < len(items) && items[i].Kind == "sets" && items[i].Superset == it.Superset && !member[i]; i++ {
+				b.Members = append(b.Members, entry(i))
+				sets = max(sets, items[i].Sets)
+			}
+			i--
+			b.relabel()
+			b.Rest = b.Members[0].Rest
+			for r := 1; r <= sets; r++ {
+				b.Rows = append(b.Rows, formRow{N: r})
+			}
+			out = append(out, b)
+		default:
+			out = append(out, formBlock{formEntry: entry(i)})
+		}
+	}
+	return out
+}
+
+// fillPrev looks up the latest logged sets of every exercise in blocks.
+func fillPrev(ctx context.Context, q db.Querier, blocks []formBlock) error {
+	var labels []string
+	for i := range blocks {
+		for _, e := range blocks[i].entries() {
+			if e.Kind == "sets" {
+				labels = append(labels, e.Label)
+			}
+		}
+	}
+	prev, err := db.PrevLatestByLabels(ctx, q, labels)
+	if err != nil {
+		return err
+	}
+	for i := range blocks {
+		for _, e := range blocks[i].entries() {
+			e.Prev = prev[e.Label]
+		}
+	}
+	return nil
+}
+
+// addedSets is how many set inputs an exercise added during a session
+// starts with.
+const addedSets = 3
+
+// addedBlock is the form block of an exercise added during the session,
+// named by index i.
+func addedBlock(i int, name string, ts timerSettings) formBlock {
+	it := plan.Item{Kind: "sets", Label: name, Sets: addedSets}
+	if ex, ok := plan.ExerciseByName(name); ok {
+		it.Exercise = ex.Slug
+		if ex.Unit == "secs" {
+			it.Note = "secs"
+		}
+	}
+	return formBlock{formEntry: formEntry{Index: i, Item: it, Planned: name, Added: true, MaxSets: plan.UnplannedMaxSets, Rest: ts.Rest(it)}}
+}
+
+// postedItems returns the index of every item on the posted form, by the
+// it_<i>_kind fields.
+func postedItems(r *http.Request) []int {
+	var idxs []int
+	for key := range r.PostForm {
+		if !strings.HasPrefix(key, "it_") || !strings.HasSuffix(key, "_kind") {
+			continue
+		}
+		if i, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(key, "it_"), "_kind")); err == nil {
+			idxs = append(idxs, i)
+		}
+	}
+	sort.Ints(idxs)
+	return idxs
+}
+
+// postedBlock finds the block holding the "sets" item planned (the plan's
+// label, or an added exercise's name) on rotation day day, filled in from
+// the posted form, and the item's entry in it. A form rendered in a deload
+// week says so, and gets deload blocks back.
+func postedBlock(r *http.Request, day int, planned string) (*formBlock, *formEntry) {
+	ts := loadTimerSettings()
+	items := planFor(day, r.PostFormValue("deload") != "")
+	blocks := formBlocks(items, ts)
+	for _, i := range postedItems(r) {
+		p := fmt.Sprintf("it_%d_", i)
+		if r.PostFormValue(p+"added") != "" && r.PostFormValue(p+"label") == planned {
+			blocks = append(blocks, addedBlock(i, planned, ts))
+		}
+	}
+	for i := range blocks {
+		b := &blocks[i]
+		for _, e := range b.entries() {
+			if e.Kind == "sets" && e.Planned == planned {
+				applyFormState(b, r)
+				return b, e
+			}
+		}
+	}
+	return nil, nil
+}
+
+// renderBlock writes b, with its exercises' history, to out.
+func renderBlock(ctx context.Context, q db.Querier, out *bytes.Buffer, b formBlock) error {
+	blocks := []formBlock{b}
+	if err := fillPrev(ctx, q, blocks); err != nil {
+		return err
+	}
+	return mustTpl("web/templates/session_new.gohtml").ExecuteTemplate(out, "block", blocks[0])
+}
+
+// applyFormState carries a posted form's swaps, set counts and values over
+// to b, so it can be re-rendered as the user left it.
+func applyFormState(b *formBlock, r *http.Request) {
+	for _, e := range b.entries() {
+		if e.Kind != "sets" {
+			continue
+		}
+		p := fmt.Sprintf("it_%d_", e.Index)
+		if l := r.PostFormValue(p + "label"); l != "" && (l == e.Planned || plan.CanSwap(e.Item, l)) {
+			e.Label = l
+		}
+		if n, err := strconv.Atoi(r.PostFormValue(p + "sets")); err == nil && n >= 1 && n <= e.MaxSets {
+			e.Sets = n
+		}
+		e.Values = map[int]string{}
+		for n := 1; n <= e.Sets; n++ {
+			e.Values[n] = r.PostFormValue(fmt.Sprintf("s_%d_%d", e.Index, n))
+		}
+	}
+	b.relabel()
+}
+
+// POST /session/items  adhoc_name=<exercise>, with the rest of the form.
+// Adds an exercise the plan doesn't have: its block is appended to #adhoc
+// out of band, and the response clears the name's error slot. Nothing is
+// stored until one of its sets is.
+func handleAddExercise(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		name := strings.Join(strings.Fields(r.PostFormValue("adhoc_name")), " ")
+		if ex, ok := plan.ExerciseByName(name); ok {
+			name = ex.Name
+		}
+		msg := checkLabel(name)
+		next := 0
+		for _, i := range postedItems(r) {
+			next = max(next, i+1)
+			p := fmt.Sprintf("it_%d_", i)
+			if msg == "" && (strings.EqualFold(r.PostFormValue(p+"label"), name) || strings.EqualFold(r.PostFormValue(p+"planned"), name)) {
+				msg = "already in this session"
+			}
+		}
+		if msg != "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(msg))
+			return
+		}
+
+		var out bytes.Buffer
+		out.WriteString(`<div hx-swap-oob="beforeend:#adhoc">`)
+		if err := renderBlock(r.Context(), pool, &out, addedBlock(next, name, loadTimerSettings())); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		out.WriteString(`</div>`)
+		out.WriteTo(w)
+	}
+}
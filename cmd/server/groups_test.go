@@ -0,0 +1,216 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"traininglog/internal/plan"
+)
+
+// blockShape is a form block as the page lays it out: its label, its
+// members' labels and how many rows (rounds or sets) it has.
+type blockShape struct {
+	Label    string
+	Superset string
+	Members  []string
+	Rows     int
+}
+
+func shapes(blocks []formBlock) []blockShape {
+	out := make([]blockShape, 0, len(blocks))
+	for _, b := range blocks {
+		s := blockShape{Label: b.Label, Superset: b.Superset, Rows: len(b.Rows)}
+		for _, m := range b.Members {
+			s.Members = append(s.Members, m.Label)
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestFormBlocks(t *testing.T) {
+	ts := timerSettings{RestSecs: 90, IntervalSecs: 60}
+	tests := []struct {
+		day  int
+		want []blockShape
+	}{
+		{1, []blockShape{
+			{Label: "foam roll"},
+			{Label: "walk 55 mins 1%, 5 mins 0%"},
+			{Label: "inc 2 pushups + green rows", Superset: "A", Members: []string{"inc 2 pushups", "green rows"}, Rows: 4},
+			{Label: "3-4 circuits", Members: []string{"bw squats", "plank"}, Rows: 4},
+			{Label: "green face pulls"},
+			{Label: "stretch"},
+			{Label: "breathe"},
+		}},
+		{9, []blockShape{
+			{Label: "foam roll"},
+			{Label: "walk 55 mins 1%, 5 mins 0%"},
+			{Label: "inc 2 pushups + green rows", Superset: "A", Members: []string{"inc 2 pushups", "green rows"}, Rows: 4},
+			{Label: "3-4 circuits", Members: []string{"bw squats", "knee raises"}, Rows: 4},
+			{Label: "band curls"},
+			{Label: "stretch"},
+			{Label: "breathe"},
+		}},
+		{3, []blockShape{
+			{Label: "foam roll"},
+			{Label: "walk 55 mins 1%, 5 mins 0%"},
+			{Label: "inc 2 pushups + purp/red band pullups", Superset: "A", Members: []string{"inc 2 pushups", "purp/red band pullups"}, Rows: 4},
+			{Label: "3-4 circuits", Members: []string{"bw split squats", "knee raises"}, Rows: 4},
+			{Label: "band curls"},
+			{Label: "stretch"},
+			{Label: "breathe"},
+		}},
+		{2, []blockShape{
+			{Label: "foam roll"},
+			{Label: "walk 55 mins 1%, 5 mins 0%"},
+			{Label: "stretch"},
+			{Label: "breathe"},
+		}},
+	}
+	for _, tt := range tests {
+		got := shapes(formBlocks(plan.Day(tt.day), ts))
+		if len(got) != len(tt.want) {
+			t.Errorf("day %d: %d blocks %+v, want %+v", tt.day, len(got), got, tt.want)
+			continue
+		}
+		for i := range got {
+			g, w := got[i], tt.want[i]
+			if g.Label != w.Label || g.Superset != w.Superset || g.Rows != w.Rows || !slices.Equal(g.Members, w.Members) {
+				t.Errorf("day %d block %d: %+v, want %+v", tt.day, i, g, w)
+			}
+		}
+	}
+}
+
+func TestFormBlocksLayout(t *testing.T) {
+	blocks := formBlocks(plan.Day(1), timerSettings{RestSecs: 90, IntervalSecs: 60})
+
+	ss := blocks[2]
+	if ss.RowName != "Set" || ss.Index != ss.Members[0].Index || ss.Rest != 90 {
+		t.Errorf("superset: rows of %q, index %d, rest %d", ss.RowName, ss.Index, ss.Rest)
+	}
+
+	c := blocks[3]
+	if c.RowName != "Round" || c.Interval != 60 {
+		t.Errorf("circuit: rows of %q, interval %d", c.RowName, c.Interval)
+	}
+	var optional []int
+	for _, r := range c.Rows {
+		if r.Optional {
+			optional = append(optional, r.N)
+		}
+	}
+	if !slices.Equal(optional, []int{4}) {
+		t.Errorf("optional rounds %v, want [4] of 3-4", optional)
+	}
+	if plank := c.Members[1]; plank.Rest != 60 || plank.MaxSets != 4+plan.MaxExtraSets {
+		t.Errorf("plank rest %d max sets %d", plank.Rest, plank.MaxSets)
+	}
+
+	// every item is on the form exactly once, under its own index
+	seen := map[int]bool{}
+	for i := range blocks {
+		for _, e := range blocks[i].entries() {
+			if e.Kind == "" {
+				continue // a superset's own entry
+			}
+			if seen[e.Index] {
+				t.Errorf("item %d twice", e.Index)
+			}
+			seen[e.Index] = true
+		}
+	}
+	if len(seen) != len(plan.Day(1)) {
+		t.Errorf("%d of %d items on the form", len(seen), len(plan.Day(1)))
+	}
+}
+
+func TestFormBlocksSwapOffers(t *testing.T) {
+	ss := formBlocks(plan.Day(1), timerSettings{})[2]
+	if got := ss.Members[0].Swaps; !slices.Equal(got, []string{"knee pushups"}) {
+		t.Errorf("pushups swaps %v", got)
+	}
+	ss.Members[0].Label = "knee pushups"
+	ss.relabel()
+	if ss.Label != "knee pushups + green rows" {
+		t.Errorf("relabelled %q", ss.Label)
+	}
+}
+
+func TestPostedBlockAdded(t *testing.T) {
+	r := postForm(url.Values{
+		"day":        {"2"},
+		"it_0_kind":  {"check"},
+		"it_0_label": {"foam roll"},
+		"it_4_kind":  {"sets"},
+		"it_4_label": {"lunges"},
+		"it_4_added": {"1"},
+		"it_4_sets":  {"5"},
+		"s_4_1":      {"12"},
+		"s_4_5":      {"9"},
+		"it_5_kind":  {"sets"},
+		"it_5_label": {"plank"},
+		"it_5_added": {"1"},
+		"it_5_sets":  {"2"},
+	})
+	b, e := postedBlock(r, 2, "lunges")
+	if b == nil {
+		t.Fatal("added exercise not found")
+	}
+	if !e.Added || e.Index != 4 || e.Sets != 5 || e.MaxSets != plan.UnplannedMaxSets || e.Value(1) != "12" || e.Value(5) != "9" {
+		t.Errorf("lunges entry %+v", *e)
+	}
+	if _, e := postedBlock(r, 2, "plank"); e == nil || e.Note != "secs" || e.Exercise != "plank" {
+		t.Errorf("plank entry %+v, want the library's timed exercise", e)
+	}
+	if b, _ := postedBlock(r, 2, "burpees"); b != nil {
+		t.Error("found an exercise the form doesn't have")
+	}
+}
+
+func TestAddExerciseRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		add  string
+		want string
+	}{
+		{"blank", "   ", "name the exercise"},
+		{"too long", strings.Repeat("x", maxLabelLen+1), "at most 80 characters"},
+		{"planned already", "inc 2 pushups", "already in this session"},
+		{"added already, other case", "Lunges", "already in this session"},
+		{"swapped in already", "knee pushups", "already in this session"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := postForm(url.Values{
+				"adhoc_name":   {tt.add},
+				"day":          {"1"},
+				"it_3_kind":    {"sets"},
+				"it_3_label":   {"knee pushups"},
+				"it_3_planned": {"inc 2 pushups"},
+				"it_12_kind":   {"sets"},
+				"it_12_label":  {"lunges"},
+				"it_12_added":  {"1"},
+			})
+			w := httptest.NewRecorder()
+			handleAddExercise(nil)(w, r)
+			if w.Code != http.StatusUnprocessableEntity || w.Body.String() != tt.want {
+				t.Errorf("got %d %q, want 422 %q", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	if got := splitList(" chest, triceps ,,core "); !slices.Equal(got, []string{"chest", "triceps", "core"}) {
+		t.Errorf("splitList = %q", got)
+	}
+	if got := splitList(""); got != nil {
+		t.Errorf("splitList(\"\") = %q, want none", got)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocalOnly(t *testing.T) {
+	h := localOnly(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		want      int
+	}{
+		{"host tool", "127.0.0.1:51000", "", http.StatusNoContent},
+		{"host tool over ipv6", "[::1]:51000", "", http.StatusNoContent},
+		{"through the proxy", "127.0.0.1:51000", "203.0.113.7", http.StatusNotFound},
+		{"remote", "203.0.113.7:51000", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/admin/backup", nil)
+			r.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRFProtect(t *testing.T) {
+	h := csrfProtect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+	tests := []struct {
+		name   string
+		method string
+		cookie string
+		header string
+		want   int
+	}{
+		{"get without cookie", http.MethodGet, "", "", http.StatusNoContent},
+		{"head", http.MethodHead, "tok", "", http.StatusNoContent},
+		{"post echoing the cookie", http.MethodPost, "tok", "tok", http.StatusNoContent},
+		{"delete echoing the cookie", http.MethodDelete, "tok", "tok", http.StatusNoContent},
+		{"post without header", http.MethodPost, "tok", "", http.StatusForbidden},
+		{"post with another token", http.MethodPost, "tok", "other", http.StatusForbidden},
+		{"post without cookie", http.MethodPost, "", "tok", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/session/save", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: csrfCookie, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set(csrfHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d", w.Code, tt.want)
+			}
+			issued := len(w.Result().Cookies()) > 0
+			if issued != (tt.cookie == "") {
+				t.Errorf("token cookie issued = %v with cookie %q", issued, tt.cookie)
+			}
+		})
+	}
+}
+
+func TestCSRFTokenRoundTrip(t *testing.T) {
+	h := csrfProtect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookie || len(cookies[0].Value) < 40 || cookies[0].HttpOnly {
+		t.Fatalf("issued %+v, want one readable %s cookie with a 32-byte token", cookies, csrfCookie)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/session/save", nil)
+	r.AddCookie(cookies[0])
+	r.Header.Set(csrfHeader, cookies[0].Value)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("post with the issued token: status %d", w.Code)
+	}
+}
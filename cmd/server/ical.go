@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/plan"
+)
+
+// projectedDays is how many training days the feed projects the rotation
+// onto: one full cycle.
+const projectedDays = 12
+
+// icsWriter emits iCalendar content lines, folding at 75 octets and ending
+// every line with CRLF as RFC 5545 requires.
+type icsWriter struct {
+	b strings.Builder
+}
+
+func (iw *icsWriter) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 { // don't split a UTF-8 sequence
+			cut--
+		}
+		iw.b.WriteString(s[:cut])
+		iw.b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	iw.b.WriteString(s)
+	iw.b.WriteString("\r\n")
+}
+
+func icsEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+func (iw *icsWriter) event(uid string, stamp time.Time, day time.Time, summary, desc, status string) {
+	iw.line("BEGIN:VEVENT")
+	iw.line("UID:" + uid)
+	iw.line("DTSTAMP:" + stamp.UTC().Format("20060102T150405Z"))
+	iw.line("DTSTART;VALUE=DATE:" + day.Format("20060102"))
+	iw.line("DTEND;VALUE=DATE:" + day.AddDate(0, 0, 1).Format("20060102"))
+	iw.line("SUMMARY:" + icsEscape(summary))
+	if desc != "" {
+		iw.line("DESCRIPTION:" + icsEscape(desc))
+	}
+	iw.line("STATUS:" + status)
+	iw.line("TRANSP:TRANSPARENT")
+	iw.line("END:VEVENT")
+}
+
+type icsWorkout struct {
+	id     int64
+	day    int
+	date   string
+	stamp  time.Time
+	length string // session duration, "" if not timed
+	sets   map[string][]int
+	checks []string
+}
+
+func (wk *icsWorkout) description() string {
+	var lines []string
+	if wk.length != "" {
+		lines = append(lines, "duration: "+wk.length)
+	}
+	labels := make([]string, 0, len(wk.sets))
+	for lbl := range wk.sets {
+		labels = append(labels, lbl)
+	}
+	sort.Strings(labels)
+	for _, lbl := range labels {
+		lines = append(lines, lbl+": "+join(wk.sets[lbl]))
+	}
+	if len(wk.checks) > 0 {
+		lines = append(lines, "done: "+strings.Join(wk.checks, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func planDescription(items []plan.Item) string {
+	var lines []string
+	for _, it := range items {
+		switch it.Kind {
+		case "sets":
+			l := fmt.Sprintf("%s %dx%d-%d", it.Label, it.Sets, it.RepsMin, it.RepsMax)
+			if it.Note != "" {
+				l += " " + it.Note
+			}
+			lines = append(lines, l)
+		case "check":
+			lines = append(lines, it.Label)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// handleCalendarICS serves completed workouts plus the projected rotation as
+// an iCalendar feed that calendar apps can subscribe to.
+func handleCalendarICS(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loc := loadLoc()
+		rows, err := pool.Query(r.Context(), `
+SELECT w.id, w.day_num, w.session_date, w.completed_at, w.started_at, w.ended_at,
+       wi.kind, wi.label, wi.value_int, wi.checked
+FROM workouts w
+LEFT JOIN workout_items wi ON wi.workout_id = w.id
+WHERE w.completed_at IS NOT NULL AND w.deleted_at IS NULL
+ORDER BY w.id, wi.label NULLS LAST, wi.set_index NULLS LAST`)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var workouts []*icsWorkout
+		var cur *icsWorkout
+		for rows.Next() {
+			var id int64
+			var day int
+			var sd, ct, st, et *time.Time
+			var kind, label *string
+			var vi *int32
+			var ch *bool
+			if err := rows.Scan(&id, &day, &sd, &ct, &st, &et, &kind, &label, &vi, &ch); err != nil {
+				http.Error(w, "db scan error", http.StatusInternalServerError)
+				return
+			}
+			if cur == nil || cur.id != id {
+				cur = &icsWorkout{id: id, day: day, date: workoutDate(sd, ct, loc), stamp: *ct, length: durationText(st, et), sets: map[string][]int{}}
+				workouts = append(workouts, cur)
+			}
+			if kind == nil || label == nil {
+				continue
+			}
+			switch *kind {
+			case "sets":
+				if vi != nil {
+					cur.sets[*label] = append(cur.sets[*label], int(*vi))
+				}
+			case "check":
+				if ch != nil && *ch {
+					cur.checks = append(cur.checks, *label)
+				}
+			}
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "db rows error", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		today := now.In(loc)
+		today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+		doneToday := false
+
+		var iw icsWriter
+		iw.line("BEGIN:VCALENDAR")
+		iw.line("VERSION:2.0")
+		iw.line("PRODID:-//traininglog//EN")
+		iw.line("CALSCALE:GREGORIAN")
+		iw.line("X-WR-CALNAME:Training Log")
+		for _, wk := range workouts {
+			d, err := time.Parse("2006-01-02", wk.date)
+			if err != nil {
+				continue
+			}
+			if d.Equal(today) {
+				doneToday = true
+			}
+			iw.event(fmt.Sprintf("workout-%d@traininglog", wk.id), wk.stamp, d,
+				fmt.Sprintf("Day %d", wk.day), wk.description(), "CONFIRMED")
+		}
+
+		// Upcoming rotation: one day per training day, starting tomorrow if
+		// today's session is already logged.
+		start := today
+		if doneToday {
+			start = start.AddDate(0, 0, 1)
+		}
+		next, _ := nextSession(r.Context(), pool)
+		sched := loadSchedule()
+		day := next.Day
+		for n, d := 0, start; n < projectedDays; d = d.AddDate(0, 0, 1) {
+			if !sched.On(d) {
+				continue
+			}
+			n++
+			phase := plan.Block.PhaseOf(next.WeekOf(d))
+			summary := fmt.Sprintf("Day %d (planned)", day)
+			if phase.Deload {
+				summary = fmt.Sprintf("Day %d (planned, deload)", day)
+			}
+			iw.event(fmt.Sprintf("planned-%s@traininglog", d.Format("20060102")), now, d,
+				summary, planDescription(plan.DayIn(day, phase)), "TENTATIVE")
+			day = day%12 + 1
+		}
+		iw.line("END:VCALENDAR")
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="traininglog.ics"`)
+		w.Write([]byte(iw.b.String()))
+	}
+}
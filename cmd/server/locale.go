@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// calLocale holds the names the calendar shows; both arrays start at Sunday
+// and January so they index directly with time.Weekday and time.Month-1.
+type calLocale struct {
+	Weekdays [7]string
+	Months   [12]string
+}
+
+var calLocales = map[string]calLocale{
+	"en": {
+		Weekdays: [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		Months:   [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	},
+	"de": {
+		Weekdays: [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+		Months:   [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+	},
+	"fr": {
+		Weekdays: [7]string{"dim", "lun", "mar", "mer", "jeu", "ven", "sam"},
+		Months:   [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+	},
+	"es": {
+		Weekdays: [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		Months:   [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+	},
+	"it": {
+		Weekdays: [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+		Months:   [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+	},
+	"nl": {
+		Weekdays: [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+		Months:   [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+	},
+}
+
+// calLangs is the order languages appear in the calendar's picker.
+var calLangs = []string{"en", "de", "fr", "es", "it", "nl"}
+
+// calSettings controls how the calendar lays out and names weeks.
+type calSettings struct {
+	WeekStart time.Weekday // time.Sunday or time.Monday
+	Lang      string       // key into calLocales
+}
+
+func (cs calSettings) locale() calLocale {
+	return calLocales[cs.Lang]
+}
+
+// MonthTitle renders e.g. "October 2026" or "Oktober 2026".
+func (cs calSettings) MonthTitle(t time.Time) string {
+	return fmt.Sprintf("%s %d", cs.locale().Months[t.Month()-1], t.Year())
+}
+
+// DayTitle renders e.g. "Sun 18 October 2026".
+func (cs calSettings) DayTitle(t time.Time) string {
+	l := cs.locale()
+	return fmt.Sprintf("%s %d %s %d", l.Weekdays[t.Weekday()], t.Day(), l.Months[t.Month()-1], t.Year())
+}
+
+// WeekdayHeaders returns the seven column headers starting at WeekStart.
+func (cs calSettings) WeekdayHeaders() []string {
+	l := cs.locale()
+	out := make([]string, 7)
+	for i := range out {
+		out[i] = l.Weekdays[(int(cs.WeekStart)+i)%7]
+	}
+	return out
+}
+
+// weekOffset is how many days t is past the start of its week.
+func (cs calSettings) weekOffset(t time.Time) int {
+	return (int(t.Weekday()) - int(cs.WeekStart) + 7) % 7
+}
+
+func parseWeekStart(s string) (time.Weekday, bool) {
+	switch strings.ToLower(s) {
+	case "sun", "sunday":
+		return time.Sunday, true
+	case "mon", "monday":
+		return time.Monday, true
+	}
+	return 0, false
+}
+
+// loadCalSettings starts from the WEEK_START and CALENDAR_LOCALE environment
+// defaults, then applies the browser's cookies. A week/lang query parameter
+// overrides both and is remembered in a cookie for later visits.
+func loadCalSettings(w http.ResponseWriter, r *http.Request) calSettings {
+	cs := calSettings{WeekStart: time.Sunday, Lang: "en"}
+	if ws, ok := parseWeekStart(os.Getenv("WEEK_START")); ok {
+		cs.WeekStart = ws
+	}
+	if _, ok := calLocales[os.Getenv("CALENDAR_LOCALE")]; ok {
+		cs.Lang = os.Getenv("CALENDAR_LOCALE")
+	}
+
+	// pick returns the query or cookie value of param that ok accepts,
+	// remembering an accepted query value.
+	pick := func(param string, ok func(string) bool) string {
+		if v := r.URL.Query().Get(param); ok(v) {
+			http.SetCookie(w, &http.Cookie{
+				Name: "cal_" + param, Value: v, Path: "/",
+				MaxAge: 365 * 24 * 3600, SameSite: http.SameSiteLaxMode,
+			})
+			return v
+		}
+		if c, err := r.Cookie("cal_" + param); err == nil && ok(c.Value) {
+			return c.Value
+		}
+		return ""
+	}
+	if v := pick("week", func(v string) bool { _, ok := parseWeekStart(v); return ok }); v != "" {
+		cs.WeekStart, _ = parseWeekStart(v)
+	}
+	if v := pick("lang", func(v string) bool { _, ok := calLocales[v]; return ok }); v != "" {
+		cs.Lang = v
+	}
+	return cs
+}
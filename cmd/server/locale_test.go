@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadCalSettings(t *testing.T) {
+	t.Setenv("WEEK_START", "")
+	t.Setenv("CALENDAR_LOCALE", "")
+	tests := []struct {
+		name    string
+		query   string
+		cookies map[string]string
+		want    calSettings
+		set     map[string]string // cookies the response sets
+	}{
+		{"defaults", "", nil, calSettings{WeekStart: time.Sunday, Lang: "en"}, nil},
+		{"query is remembered", "?week=mon&lang=de", nil,
+			calSettings{WeekStart: time.Monday, Lang: "de"}, map[string]string{"cal_week": "mon", "cal_lang": "de"}},
+		{"cookies", "", map[string]string{"cal_week": "monday", "cal_lang": "fr"},
+			calSettings{WeekStart: time.Monday, Lang: "fr"}, nil},
+		{"query beats cookie", "?lang=nl", map[string]string{"cal_lang": "fr"},
+			calSettings{WeekStart: time.Sunday, Lang: "nl"}, map[string]string{"cal_lang": "nl"}},
+		{"bad query is not remembered", "?week=tue&lang=xx", map[string]string{"cal_lang": "it"},
+			calSettings{WeekStart: time.Sunday, Lang: "it"}, nil},
+		{"bad cookie is ignored", "", map[string]string{"cal_week": "fri", "cal_lang": "xx"},
+			calSettings{WeekStart: time.Sunday, Lang: "en"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/calendar"+tt.query, nil)
+			for k, v := range tt.cookies {
+				r.AddCookie(&http.Cookie{Name: k, Value: v})
+			}
+			w := httptest.NewRecorder()
+			if got := loadCalSettings(w, r); got != tt.want {
+				t.Errorf("settings = %+v, want %+v", got, tt.want)
+			}
+			set := map[string]string{}
+			for _, c := range w.Result().Cookies() {
+				set[c.Name] = c.Value
+			}
+			if len(set) != len(tt.set) {
+				t.Fatalf("set cookies %v, want %v", set, tt.set)
+			}
+			for k, v := range tt.set {
+				if set[k] != v {
+					t.Errorf("cookie %s = %q, want %q", k, set[k], v)
+				}
+			}
+		})
+	}
+}
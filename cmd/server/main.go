@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -41,6 +44,7 @@ type listRow struct {
 	DayNum    int
 	Date      string
 	Completed bool
+	Duration  string // "" when start or end wasn't recorded
 }
 
 type checkRow struct {
@@ -57,6 +61,32 @@ type DayCell struct {
 	InMonth   bool
 	Completed bool
 	Count     int
+	Sessions  []CellSession
+
+	Training    bool   // a scheduled training weekday
+	Planned     int    // rotation day projected onto it, 0 if none
+	PlannedType string // plan.DayType of Planned
+	Missed      bool   // a past training day without a session
+}
+
+// PlannedShort is the badge text of the planned session: "A", "B" or "easy".
+func (c DayCell) PlannedShort() string {
+	return strings.TrimPrefix(c.PlannedType, "strength ")
+}
+
+// CellSession is one completed workout shown in a calendar cell.
+type CellSession struct {
+	ID     int64
+	DayNum int
+	Type   string // plan.DayType, e.g. "strength A"
+
+	Duration   time.Duration // 0 when start or end wasn't recorded
+	BodyWeight *float64
+}
+
+// Short is the compact badge text for a cell: "A", "B" or "easy".
+func (s CellSession) Short() string {
+	return strings.TrimPrefix(s.Type, "strength ")
 }
 
 func loadLoc() *time.Location {
@@ -83,20 +113,20 @@ func monthBounds(t time.Time) (time.Time, time.Time) {
 	return start, end
 }
 
-func buildCells(loc *time.Location, month time.Time, counts map[string]int) []DayCell {
+func buildCells(loc *time.Location, month time.Time, cs calSettings, byDay map[string][]CellSession) []DayCell {
 	startOfMonth := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, loc)
-	weekday := int(startOfMonth.Weekday()) // 0=Sun
-	gridStart := startOfMonth.AddDate(0, 0, -weekday)
+	gridStart := startOfMonth.AddDate(0, 0, -cs.weekOffset(startOfMonth))
 	cells := make([]DayCell, 42)
 	for i := 0; i < 42; i++ {
 		d := gridStart.AddDate(0, 0, i)
 		key := d.Format("2006-01-02")
-		c := counts[key]
+		ss := byDay[key]
 		cells[i] = DayCell{
 			Date:      d,
 			InMonth:   d.Month() == month.Month(),
-			Completed: c > 0,
-			Count:     c,
+			Completed: len(ss) > 0,
+			Count:     len(ss),
+			Sessions:  ss,
 		}
 	}
 	return cells
@@ -118,6 +148,35 @@ func main() {
 		log.Fatalf("migrate: %v", err)
 	}
 
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, pool, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	// Data fix-ups for rows written before a feature existed. They run after
+	// the command dispatch so `restore` still finds an empty database.
+	if err := setupExercises(ctx, pool); err != nil {
+		log.Fatalf("exercises: %v", err)
+	}
+	if err := backfillGroups(ctx, pool); err != nil {
+		log.Fatalf("backfill groups: %v", err)
+	}
+
+	// Reminders for training days without a session, if a notifier is set up.
+	if n, err := loadNotifier(); err != nil {
+		log.Printf("reminders off: %v", err)
+	} else if n != nil {
+		go runReminders(ctx, pool, n)
+	}
+
+	// Outbound webhooks on workout events, if URLs are configured.
+	hooks, err := loadWebhooks()
+	if err != nil {
+		log.Printf("webhooks off: %v", err)
+	}
+
 	dbStatus := "down"
 	if err := db.Ping(ctx, pool); err == nil {
 		dbStatus = "ok"
@@ -126,16 +185,38 @@ func main() {
 	mux := http.NewServeMux()
 
 	staticDir := filepath.FromSlash("web/static/dist")
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
-
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
+
+	// Offline support: page script plus a root-scoped service worker.
+	jsDir := filepath.FromSlash("web/static/js")
+	mux.Handle("GET /js/", http.StripPrefix("/js/", http.FileServer(http.Dir(jsDir))))
+	mux.HandleFunc("GET /sw.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeFile(w, r, filepath.Join(jsDir, "sw.js"))
+	})
+	mux.HandleFunc("POST /sync/workouts/{uuid}", handleSync(pool, hooks))
+
+	// Autosave of single inputs; {id} is the workout id or, before the first
+	// save, the form's client UUID.
+	mux.HandleFunc("PUT /sessions/{id}/items/{label}/sets/{n}", handleAutosave(pool, autosaveSet))
+	mux.HandleFunc("PUT /sessions/{id}/items/{label}/check", handleAutosave(pool, autosaveCheck))
+	mux.HandleFunc("PUT /sessions/{id}/items/{label}/sets", handleAutosave(pool, autosaveSetCount))
+	mux.HandleFunc("PUT /sessions/{id}/items/{label}/swap", handleAutosave(pool, autosaveSwap))
+	mux.HandleFunc("POST /session/items", handleAddExercise(pool))
+	mux.HandleFunc("PUT /sessions/{id}/meta/{field}", handleAutosave(pool, autosaveMeta))
+	mux.HandleFunc("POST /sessions/{id}/rests", handleAutosave(pool, autosaveRest))
+
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
 		t := mustTpl("web/templates/base.gohtml", "web/templates/index.gohtml")
+		next, phase := nextSession(r.Context(), pool)
 		data := struct {
 			DBStatus string
 			NextDay  int
+			Phase    plan.Phase
 		}{
 			DBStatus: dbStatus,
-			NextDay:  db.NextDay(r.Context(), pool),
+			NextDay:  next.Day,
+			Phase:    phase,
 		}
 		if err := t.ExecuteTemplate(w, "base.gohtml", data); err != nil {
 			http.Error(w, "template error", http.StatusInternalServerError)
@@ -143,67 +224,58 @@ func main() {
 		}
 	})
 
-	mux.HandleFunc("/calendar", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("GET /calendar", func(w http.ResponseWriter, r *http.Request) {
 		loc := loadLoc()
+		cs := loadCalSettings(w, r)
 		month, _ := monthFromQuery(r, loc)
 		startLocal, endLocal := monthBounds(month)
-		startUTC, endUTC := startLocal.UTC(), endLocal.UTC()
 
-		type row struct {
-			SessionDate *time.Time
-			CompletedAt time.Time
-		}
-
-		rows, err := pool.Query(r.Context(),
-			`SELECT session_date, completed_at
-			FROM workouts
-			WHERE completed_at IS NOT NULL
-				AND (
-					(session_date IS NOT NULL AND session_date >= $1 AND session_date < $2)
-				OR (session_date IS NULL   AND completed_at >= $3 AND completed_at < $4)
-					)`,
-			startLocal, endLocal, startUTC, endUTC)
+		byDay, err := completedByDay(r.Context(), pool, loc, startLocal, endLocal)
 		if err != nil {
 			http.Error(w, "db error", http.StatusInternalServerError)
 			return
 		}
-		defer rows.Close()
 
-		counts := map[string]int{}
-		for rows.Next() {
-			var sd *time.Time
-			var ct time.Time
-			if err := rows.Scan(&sd, &ct); err != nil {
-				http.Error(w, "db error", http.StatusInternalServerError)
-				return
-			}
-			var key string
-			if sd != nil {
-				key = sd.In(loc).Format("2006-01-02")
-			} else {
-				key = ct.In(loc).Format("2006-01-02")
-			}
-			counts[key]++
+		now := time.Now().In(loc)
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		todays, err := completedByDay(r.Context(), pool, loc, today, today.AddDate(0, 0, 1))
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
 		}
-		if err := rows.Err(); err != nil {
+		var first *time.Time
+		if d, ok, err := db.FirstCompletedDate(r.Context(), pool, loc); err != nil {
 			http.Error(w, "db error", http.StatusInternalServerError)
 			return
+		} else if ok {
+			first = &d
 		}
+		nextSess, _ := nextSession(r.Context(), pool)
+		cells := buildCells(loc, month, cs, byDay)
+		markSchedule(cells, loadSchedule(), today, len(todays) > 0, first, nextSess.Day)
 
 		prev := month.AddDate(0, -1, 0).Format("2006-01")
 		next := month.AddDate(0, 1, 0).Format("2006-01")
-		title := month.Format("January 2006")
+		ym := month.Format("2006-01")
 
 		data := struct {
-			Title  string
-			PrevYM string
-			NextYM string
-			Cells  []DayCell
+			Title    string
+			YM       string
+			PrevYM   string
+			NextYM   string
+			Weekdays []string
+			Settings calSettings
+			Langs    []string
+			Cells    []DayCell
 		}{
-			Title:  title,
-			PrevYM: prev,
-			NextYM: next,
-			Cells:  buildCells(loc, month, counts),
+			Title:    cs.MonthTitle(month),
+			YM:       ym,
+			PrevYM:   prev,
+			NextYM:   next,
+			Weekdays: cs.WeekdayHeaders(),
+			Settings: cs,
+			Langs:    calLangs,
+			Cells:    cells,
 		}
 
 		files := []string{
@@ -212,7 +284,7 @@ func main() {
 		}
 		t := mustTpl(files...)
 		if r.Header.Get("HX-Request") == "true" {
-			if err := t.ExecuteTemplate(w, "calendar.gohtml", data); err != nil {
+			if err := t.ExecuteTemplate(w, "content", data); err != nil {
 				http.Error(w, "template error", http.StatusInternalServerError)
 				return
 			}
@@ -224,28 +296,62 @@ func main() {
 		}
 	})
 
-	mux.HandleFunc("/session/new", func(w http.ResponseWriter, r *http.Request) {
-		day := db.NextDay(r.Context(), pool)
-		items := plan.Day(day)
+	// Popover fragment listing the completed sessions on one calendar day.
+	mux.HandleFunc("GET /calendar/day", func(w http.ResponseWriter, r *http.Request) {
+		loc := loadLoc()
+		cs := loadCalSettings(w, r)
+		d, err := time.Parse("2006-01-02", r.URL.Query().Get("d"))
+		if err != nil {
+			http.Error(w, "bad date", http.StatusBadRequest)
+			return
+		}
+		startLocal := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc)
+		byDay, err := completedByDay(r.Context(), pool, loc, startLocal, startLocal.AddDate(0, 0, 1))
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		sessions := byDay[startLocal.Format("2006-01-02")]
 
-		// collect labels that have sets
-		var labels []string
-		for _, it := range items {
-			if it.Kind == "sets" {
-				labels = append(labels, it.Label)
-			}
+		data := struct {
+			Date     string
+			Sessions []CellSession
+		}{Date: cs.DayTitle(d), Sessions: sessions}
+		t := mustTpl("web/templates/calendar_day.gohtml")
+		if err := t.ExecuteTemplate(w, "calendar_day.gohtml", data); err != nil {
+			http.Error(w, "template error", http.StatusInternalServerError)
+			return
+		}
+	})
+
+	mux.HandleFunc("GET /stats", handleStats(pool))
+	mux.HandleFunc("GET /stats/volume", handleVolume(pool))
+	mux.HandleFunc("GET /reports", handleReportsIndex)
+	mux.HandleFunc("GET /reports/{kind}/{key}", handleReport(pool))
+
+	mux.HandleFunc("GET /calendar.ics", handleCalendarICS(pool))
+
+	mux.HandleFunc("GET /session/new", func(w http.ResponseWriter, r *http.Request) {
+		next, phase := nextSession(r.Context(), pool)
+		day := next.Day
+		blocks := formBlocks(plan.DayIn(day, phase), loadTimerSettings())
+		fillPrev(r.Context(), pool, blocks)
+		var names []string
+		exercises, _ := db.Exercises(r.Context(), pool)
+		for _, e := range exercises {
+			names = append(names, e.Name)
 		}
-		prev, _ := db.PrevLatestByLabels(r.Context(), pool, labels)
 
 		t := mustTpl("web/templates/base.gohtml", "web/templates/session_new.gohtml")
 		today := time.Now().In(loadLoc()).Format("2006-01-02")
 		data := struct {
 			Day       int
-			Items     []plan.Item
+			Phase     plan.Phase
+			Blocks    []formBlock
 			WorkoutID int64
-			Prev      map[string][]int
 			Today     string
-		}{Day: day, Items: items, WorkoutID: 0, Prev: prev, Today: today}
+			Exercises []string // suggestions for adding an exercise
+		}{Day: day, Phase: phase, Blocks: blocks, WorkoutID: 0, Today: today, Exercises: names}
 		if err := t.ExecuteTemplate(w, "base.gohtml", data); err != nil {
 			http.Error(w, "template error", http.StatusInternalServerError)
 			return
@@ -253,96 +359,73 @@ func main() {
 	})
 
 	// Save draft: create workout if needed, then persist metadata and items
-	mux.HandleFunc("/session/save", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("POST /session/save", func(w http.ResponseWriter, r *http.Request) {
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "bad form", http.StatusBadRequest)
 			return
 		}
+		f, errs := parseSessionForm(r)
+		if errs != nil {
+			writeFieldErrors(w, f, errs, "Not saved.")
+			return
+		}
 
-		var workoutID int64
-		if idStr := r.FormValue("workout_id"); idStr != "" {
-			if id, err := strconv.ParseInt(idStr, 10, 64); err == nil {
-				workoutID = id
-			}
+		ctx := r.Context()
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		if err := resolveWorkout(ctx, tx, f); errors.Is(err, errNoWorkout) {
+			http.Error(w, goneMessage, http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
 		}
-		if workoutID == 0 {
-			day, _ := strconv.Atoi(r.FormValue("day"))
-			const ins = `INSERT INTO workouts(day_num) VALUES ($1) RETURNING id`
-			if err := pool.QueryRow(r.Context(), ins, day).Scan(&workoutID); err != nil {
+		var before json.RawMessage
+		if f.WorkoutID != 0 {
+			if before, err = db.Snapshot(ctx, tx, f.WorkoutID); err != nil {
 				http.Error(w, "db error", http.StatusInternalServerError)
 				return
 			}
-			// send hidden id back so subsequent posts include it
-			fmt.Fprintf(w, `<input type="hidden" id="workout_id" name="workout_id" value="%d" hx-swap-oob="outerHTML">`, workoutID)
 		}
-
-		// Metadata after workout exists
-		if d := r.FormValue("session_date"); d != "" {
-			if t, err := time.ParseInLocation("2006-01-02", d, loadLoc()); err == nil {
-				_, _ = pool.Exec(r.Context(), `UPDATE workouts SET session_date=$2 WHERE id=$1`, workoutID, t)
-			}
+		created, err := persistSession(ctx, tx, f)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
 		}
-		if bw := r.FormValue("body_weight_kg"); bw != "" {
-			_, _ = pool.Exec(r.Context(), `UPDATE workouts SET body_weight_kg=$2 WHERE id=$1`, workoutID, bw)
+		if err := auditSave(ctx, tx, r, f.WorkoutID, before); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
 		}
-
-		// Persist items idempotently
-		for key := range r.PostForm {
-			if !strings.HasPrefix(key, "it_") || !strings.HasSuffix(key, "_kind") {
-				continue
-			}
-			idx := strings.TrimSuffix(strings.TrimPrefix(key, "it_"), "_kind")
-			kind := r.PostFormValue("it_" + idx + "_kind")
-			label := r.PostFormValue("it_" + idx + "_label")
-
-			if _, err := pool.Exec(r.Context(), `DELETE FROM workout_items WHERE workout_id=$1 AND label=$2`, workoutID, label); err != nil {
-				http.Error(w, "db error", http.StatusInternalServerError)
-				return
-			}
-
-			switch kind {
-			case "check":
-				checked := r.PostFormValue("c_"+idx) != ""
-				if _, err := pool.Exec(r.Context(),
-					`INSERT INTO workout_items(workout_id,kind,label,checked) VALUES ($1,'check',$2,$3)`,
-					workoutID, label, checked,
-				); err != nil {
-					http.Error(w, "db error", http.StatusInternalServerError)
-					return
-				}
-			case "sets":
-				sets, _ := strconv.Atoi(r.PostFormValue("it_" + idx + "_sets"))
-				for s := 1; s <= sets; s++ {
-					vStr := r.PostFormValue(fmt.Sprintf("s_%s_%d", idx, s))
-					if vStr == "" {
-						continue
-					}
-					v, err := strconv.Atoi(vStr)
-					if err != nil {
-						http.Error(w, "bad number", http.StatusBadRequest)
-						return
-					}
-					if _, err := pool.Exec(r.Context(),
-						`INSERT INTO workout_items(workout_id,kind,label,set_index,value_int) VALUES ($1,'sets',$2,$3,$4)`,
-						workoutID, label, s, v,
-					); err != nil {
-						http.Error(w, "db error", http.StatusInternalServerError)
-						return
-					}
-				}
-			}
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
 		}
 
 		w.Header().Set("HX-Trigger", "saved")
-		w.Write([]byte("Saved"))
+		w.Header().Set("X-Revision", strconv.Itoa(f.Revision))
+		if created {
+			// send hidden id back so subsequent posts include it
+			fmt.Fprintf(w, `<input type="hidden" id="workout_id" name="workout_id" value="%d" hx-swap-oob="outerHTML">`, f.WorkoutID)
+		}
+		writeFieldErrors(w, f, nil, "Saved")
 	})
 
 	// Mark complete: set completed_at and redirect home
-	mux.HandleFunc("/session/complete", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("POST /session/complete", func(w http.ResponseWriter, r *http.Request) {
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "bad form", http.StatusBadRequest)
 			return
 		}
+		f, errs := parseSessionForm(r)
+		if errs != nil {
+			writeFieldErrors(w, f, errs, "Not completed.")
+			return
+		}
 
 		ctx := r.Context()
 		tx, err := pool.Begin(ctx)
@@ -352,112 +435,74 @@ func main() {
 		}
 		defer tx.Rollback(ctx)
 
-		// ensure workout exists
-		var workoutID int64
-		if idStr := r.FormValue("workout_id"); idStr != "" {
-			if id, err := strconv.ParseInt(idStr, 10, 64); err == nil {
-				workoutID = id
-			}
-		}
-		if workoutID == 0 {
-			day, _ := strconv.Atoi(r.FormValue("day"))
-			if err := tx.QueryRow(ctx, `INSERT INTO workouts(day_num) VALUES ($1) RETURNING id`, day).Scan(&workoutID); err != nil {
-				http.Error(w, "db error", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		// metadata (date, body weight)
-		if d := r.FormValue("session_date"); d != "" {
-			if t, err := time.ParseInLocation("2006-01-02", d, loadLoc()); err == nil {
-				if _, err := tx.Exec(ctx, `UPDATE workouts SET session_date=$2 WHERE id=$1`, workoutID, t); err != nil {
-					http.Error(w, "db error", http.StatusInternalServerError)
-					return
-				}
-			}
+		if err := resolveWorkout(ctx, tx, f); errors.Is(err, errNoWorkout) {
+			http.Error(w, goneMessage, http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
 		}
-		if bw := r.FormValue("body_weight_kg"); bw != "" {
-			if _, err := tx.Exec(ctx, `UPDATE workouts SET body_weight_kg=$2 WHERE id=$1`, workoutID, bw); err != nil {
+		var before json.RawMessage
+		if f.WorkoutID != 0 {
+			if before, err = db.Snapshot(ctx, tx, f.WorkoutID); err != nil {
 				http.Error(w, "db error", http.StatusInternalServerError)
 				return
 			}
 		}
-
-		// persist items idempotently (same logic as save)
-		for key := range r.PostForm {
-			if !strings.HasPrefix(key, "it_") || !strings.HasSuffix(key, "_kind") {
-				continue
-			}
-			idx := strings.TrimSuffix(strings.TrimPrefix(key, "it_"), "_kind")
-			kind := r.PostFormValue("it_" + idx + "_kind")
-			label := r.PostFormValue("it_" + idx + "_label")
-
-			if _, err := tx.Exec(ctx, `DELETE FROM workout_items WHERE workout_id=$1 AND label=$2`, workoutID, label); err != nil {
-				http.Error(w, "db error", http.StatusInternalServerError)
-				return
-			}
-
-			switch kind {
-			case "check":
-				checked := r.PostFormValue("c_"+idx) != ""
-				if _, err := tx.Exec(ctx,
-					`INSERT INTO workout_items(workout_id,kind,label,checked) VALUES ($1,'check',$2,$3)`,
-					workoutID, label, checked,
-				); err != nil {
-					http.Error(w, "db error", http.StatusInternalServerError)
-					return
-				}
-			case "sets":
-				sets, _ := strconv.Atoi(r.PostFormValue("it_" + idx + "_sets"))
-				for s := 1; s <= sets; s++ {
-					vStr := r.PostFormValue(fmt.Sprintf("s_%s_%d", idx, s))
-					if vStr == "" {
-						continue
-					}
-					v, err := strconv.Atoi(vStr)
-					if err != nil {
-						http.Error(w, "bad number", http.StatusBadRequest)
-						return
-					}
-					if _, err := tx.Exec(ctx,
-						`INSERT INTO workout_items(workout_id,kind,label,set_index,value_int) VALUES ($1,'sets',$2,$3,$4)`,
-						workoutID, label, s, v,
-					); err != nil {
-						http.Error(w, "db error", http.StatusInternalServerError)
-						return
-					}
-				}
-			}
+		if _, err := persistSession(ctx, tx, f); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
 		}
 
 		// complete
-		tag, err := tx.Exec(ctx, `UPDATE workouts SET completed_at=now() WHERE id=$1`, workoutID)
+		tag, err := tx.Exec(ctx, `UPDATE workouts SET completed_at=now(), ended_at=COALESCE(ended_at, now()) WHERE id=$1 AND deleted_at IS NULL`, f.WorkoutID)
 		if err != nil || tag.RowsAffected() != 1 {
 			http.Error(w, "db error", http.StatusInternalServerError)
 			return
 		}
+		after, err := db.Snapshot(ctx, tx, f.WorkoutID)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if err := db.RecordAudit(ctx, tx, db.AuditEvent{
+			WorkoutID: f.WorkoutID, Action: db.AuditComplete, Actor: actorOf(r), Before: before, After: after,
+		}); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
 		if err := tx.Commit(ctx); err != nil {
 			http.Error(w, "db error", http.StatusInternalServerError)
 			return
 		}
 
+		// re-completing after an edit is not a new completion
+		if !completedIn(before) {
+			hooks.workoutCompleted(ctx, pool, f.WorkoutID)
+		}
+
 		w.Header().Set("HX-Redirect", "/")
 		w.Write([]byte("Completed"))
 	})
 
 	// CSV export: one row per workout item (checks and sets). Includes workout metadata.
-	mux.HandleFunc("/export.csv", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("GET /export.csv", func(w http.ResponseWriter, r *http.Request) {
 		type row struct {
 			WID          int64
 			DayNum       int
 			SessionDate  *time.Time
 			BodyWeightKg *float64
 			CompletedAt  *time.Time
+			StartedAt    *time.Time
+			EndedAt      *time.Time
 			Kind         *string
 			Label        *string
 			SetIndex     *int32
 			ValueInt     *int32
 			Checked      *bool
+			Round        *int32
+			Superset     *string
+			PlannedLabel *string
 		}
 
 		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
@@ -468,7 +513,8 @@ func main() {
 
 		_ = writer.Write([]string{
 			"workout_id", "day_num", "session_date", "body_weight_kg", "completed_at",
-			"kind", "label", "set_index", "value_int", "checked",
+			"started_at", "ended_at", "duration_min",
+			"kind", "label", "set_index", "value_int", "checked", "round", "superset", "planned_label",
 		})
 
 		q := `
@@ -478,13 +524,19 @@ SELECT
   w.session_date,
   w.body_weight_kg,
   w.completed_at,
+  w.started_at,
+  w.ended_at,
   wi.kind,
   wi.label,
   wi.set_index,
   wi.value_int,
-  wi.checked
+  wi.checked,
+  wi.round,
+  wi.superset,
+  wi.planned_label
 FROM workouts w
 LEFT JOIN workout_items wi ON wi.workout_id = w.id
+WHERE w.deleted_at IS NULL
 ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 `
 		rows, err := pool.Query(r.Context(), q)
@@ -502,11 +554,16 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 				&rr.SessionDate,
 				&rr.BodyWeightKg,
 				&rr.CompletedAt,
+				&rr.StartedAt,
+				&rr.EndedAt,
 				&rr.Kind,
 				&rr.Label,
 				&rr.SetIndex,
 				&rr.ValueInt,
 				&rr.Checked,
+				&rr.Round,
+				&rr.Superset,
+				&rr.PlannedLabel,
 			); err != nil {
 				http.Error(w, "db scan error", http.StatusInternalServerError)
 				return
@@ -514,7 +571,7 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 
 			sd := ""
 			if rr.SessionDate != nil {
-				sd = rr.SessionDate.In(loadLoc()).Format("2006-01-02")
+				sd = rr.SessionDate.Format("2006-01-02")
 			}
 			bw := ""
 			if rr.BodyWeightKg != nil {
@@ -524,6 +581,16 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 			if rr.CompletedAt != nil {
 				ca = rr.CompletedAt.In(loadLoc()).Format(time.RFC3339)
 			}
+			sa, ea, dm := "", "", ""
+			if rr.StartedAt != nil {
+				sa = rr.StartedAt.In(loadLoc()).Format(time.RFC3339)
+			}
+			if rr.EndedAt != nil {
+				ea = rr.EndedAt.In(loadLoc()).Format(time.RFC3339)
+			}
+			if d, ok := sessionDuration(rr.StartedAt, rr.EndedAt); ok {
+				dm = strconv.Itoa(int(d / time.Minute))
+			}
 			kind := ""
 			if rr.Kind != nil {
 				kind = *rr.Kind
@@ -548,12 +615,25 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 					ch = "false"
 				}
 			}
+			rd := ""
+			if rr.Round != nil {
+				rd = strconv.Itoa(int(*rr.Round))
+			}
+			ss := ""
+			if rr.Superset != nil {
+				ss = *rr.Superset
+			}
+			pl := ""
+			if rr.PlannedLabel != nil {
+				pl = *rr.PlannedLabel
+			}
 
 			if err := writer.Write([]string{
 				strconv.FormatInt(rr.WID, 10),
 				strconv.Itoa(rr.DayNum),
 				sd, bw, ca,
-				kind, label, si, vi, ch,
+				sa, ea, dm,
+				kind, label, si, vi, ch, rd, ss, pl,
 			}); err != nil {
 				http.Error(w, "csv write error", http.StatusInternalServerError)
 				return
@@ -565,14 +645,30 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 		}
 	})
 
-	mux.HandleFunc("/sessions", func(w http.ResponseWriter, r *http.Request) {
+	// Full backup as a JSON archive; load it with `traininglog restore <file>`.
+	// Only from the host itself (the deploy script's pre-deploy snapshot):
+	// the proxy has no auth in front of it.
+	mux.HandleFunc("GET /admin/backup", localOnly(func(w http.ResponseWriter, r *http.Request) {
+		a, err := db.Backup(r.Context(), pool)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf(`attachment; filename="traininglog_backup_%s.json"`, a.CreatedAt.Format("20060102150405")))
+		if err := json.NewEncoder(w).Encode(a); err != nil {
+			log.Printf("backup write: %v", err)
+		}
+	}))
+
+	// Sessions list: filters + search, keyset-paginated. htmx requests (filter
+	// changes and the infinite-scroll sentinel) get just the table rows.
+	mux.HandleFunc("GET /sessions", func(w http.ResponseWriter, r *http.Request) {
 		loc := loadLoc()
-		rows, err := pool.Query(r.Context(), `
-	SELECT id, day_num, session_date, completed_at
-	FROM workouts
-	ORDER BY COALESCE(session_date, (completed_at AT TIME ZONE 'UTC' AT TIME ZONE $1)::date) DESC NULLS LAST, id DESC
-	LIMIT 100
-	`, loc.String())
+		f := parseSessionFilter(r.URL.Query())
+		q, args := f.query(loc.String())
+		rows, err := pool.Query(r.Context(), q, args...)
 		if err != nil {
 			http.Error(w, "db error", http.StatusInternalServerError)
 			return
@@ -580,74 +676,139 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 		defer rows.Close()
 
 		var out []listRow
+		var sortDates []time.Time
 		for rows.Next() {
 			var id int64
 			var day int
 			var sd *time.Time
 			var ct *time.Time
-			if err := rows.Scan(&id, &day, &sd, &ct); err != nil {
+			var st, et *time.Time
+			var sortDate time.Time
+			if err := rows.Scan(&id, &day, &sd, &ct, &st, &et, &sortDate); err != nil {
 				http.Error(w, "db scan error", http.StatusInternalServerError)
 				return
 			}
-			var dstr string
-			if sd != nil {
-				dstr = sd.In(loc).Format("2006-01-02")
-			} else if ct != nil {
-				dstr = ct.In(loc).Format("2006-01-02")
-			}
 			out = append(out, listRow{
 				ID:        id,
 				DayNum:    day,
-				Date:      dstr,
+				Date:      workoutDate(sd, ct, loc),
 				Completed: ct != nil,
+				Duration:  durationText(st, et),
 			})
+			sortDates = append(sortDates, sortDate)
 		}
 		if err := rows.Err(); err != nil {
 			http.Error(w, "db rows error", http.StatusInternalServerError)
 			return
 		}
 
-		t := mustTpl("web/templates/base.gohtml", "web/templates/sessions.gohtml")
-		if err := t.ExecuteTemplate(w, "base.gohtml", struct{ Rows []listRow }{Rows: out}); err != nil {
+		// the query fetches one extra row to learn whether another page exists
+		var next string
+		if len(out) > sessionsPageSize {
+			out = out[:sessionsPageSize]
+			last := out[len(out)-1]
+			next = f.nextURL(sortDates[len(out)-1].Format("2006-01-02"), last.ID)
+		}
+
+		data := struct {
+			Rows    []listRow
+			Next    string
+			F       sessionFilter
+			Labels  []string
+			DayNums []int
+			Undo    *undoToast
+		}{Rows: out, Next: next, F: f, DayNums: seq(12)}
+		if f.deleted != 0 {
+			data.Undo = &undoToast{ID: f.deleted, Redirect: true}
+		}
+
+		t := mustTpl("web/templates/base.gohtml", "web/templates/sessions.gohtml", "web/templates/toast.gohtml")
+		if isHX(r) {
+			if err := t.ExecuteTemplate(w, "rows", data); err != nil {
+				http.Error(w, "template error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		data.Labels, err = db.ItemLabels(r.Context(), pool)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if err := t.ExecuteTemplate(w, "base.gohtml", data); err != nil {
 			http.Error(w, "template error", http.StatusInternalServerError)
 			return
 		}
 	})
 
-	mux.HandleFunc("/sessions/", func(w http.ResponseWriter, r *http.Request) {
-		rem := strings.TrimPrefix(r.URL.Path, "/sessions/")
-		// delete endpoint: /sessions/{id}/delete[?redirect=1]
-		if strings.HasSuffix(rem, "/delete") {
-			idPart := strings.TrimSuffix(rem, "/delete")
-			idPart = strings.TrimSuffix(idPart, "/")
-			id, err := strconv.ParseInt(idPart, 10, 64)
-			if err != nil || id <= 0 {
-				http.NotFound(w, r)
-				return
-			}
-			tag, err := pool.Exec(r.Context(), `DELETE FROM workouts WHERE id=$1`, id)
-			if err != nil {
-				http.Error(w, "db error", http.StatusInternalServerError)
-				return
-			}
-			if tag.RowsAffected() != 1 {
-				http.NotFound(w, r)
-				return
-			}
-			// If called from detail view: redirect back to list. From list row: return empty to remove row via hx-swap=outerHTML.
-			if r.URL.Query().Get("redirect") == "1" {
-				w.Header().Set("HX-Redirect", "/sessions")
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("Deleted"))
-				return
-			}
+	mux.HandleFunc("GET /trash", handleTrash(pool))
+	mux.HandleFunc("POST /trash/empty", handleTrashEmpty(pool))
+	mux.HandleFunc("POST /trash/{id}/purge", handleTrashPurge(pool))
+
+	// Soft delete: the workout moves to /trash until restored or purged.
+	// ?redirect=1 when called from the detail view.
+	mux.HandleFunc("POST /sessions/{id}/delete", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(r)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		ok, err := auditedExec(r.Context(), pool, r, id, db.AuditDelete,
+			`UPDATE workouts SET deleted_at=now() WHERE id=$1 AND deleted_at IS NULL`)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		hooks.workoutDeleted(r.Context(), pool, id)
+		// If called from detail view: redirect back to list, which shows the undo toast.
+		if r.URL.Query().Get("redirect") == "1" {
+			w.Header().Set("HX-Redirect", fmt.Sprintf("/sessions?deleted=%d", id))
 			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Deleted"))
+			return
+		}
+		// From list row: the empty main response removes the row via
+		// hx-swap=outerHTML; the undo toast is swapped in out of band.
+		t := mustTpl("web/templates/toast.gohtml")
+		if err := t.ExecuteTemplate(w, "toast.gohtml", undoToast{ID: id}); err != nil {
+			http.Error(w, "template error", http.StatusInternalServerError)
+		}
+	})
+
+	// Restore from the trash or the undo toast (?redirect=1 / ?refresh=1).
+	mux.HandleFunc("POST /sessions/{id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(r)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		ok, err := auditedExec(r.Context(), pool, r, id, db.AuditRestore,
+			`UPDATE workouts SET deleted_at=NULL WHERE id=$1 AND deleted_at IS NOT NULL`)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
 			return
 		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch {
+		case r.URL.Query().Get("redirect") == "1":
+			w.Header().Set("HX-Redirect", fmt.Sprintf("/sessions/%d", id))
+		case r.URL.Query().Get("refresh") == "1":
+			w.Header().Set("HX-Refresh", "true")
+		}
+		// From /trash: empty response removes the row.
+		w.WriteHeader(http.StatusOK)
+	})
 
-		// detail endpoint: /sessions/{id}
-		id, err := strconv.ParseInt(rem, 10, 64)
-		if err != nil || id <= 0 {
+	mux.HandleFunc("GET /sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(r)
+		if !ok {
 			http.NotFound(w, r)
 			return
 		}
@@ -657,16 +818,18 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 		var sd *time.Time
 		var bw *float64
 		var ct *time.Time
-		err = pool.QueryRow(r.Context(),
-			`SELECT day_num, session_date, body_weight_kg, completed_at FROM workouts WHERE id=$1`, id).
-			Scan(&day, &sd, &bw, &ct)
+		var notes *string
+		var st, et *time.Time
+		err := pool.QueryRow(r.Context(),
+			`SELECT day_num, session_date, body_weight_kg, completed_at, notes, started_at, ended_at FROM workouts WHERE id=$1 AND deleted_at IS NULL`, id).
+			Scan(&day, &sd, &bw, &ct, &notes, &st, &et)
 		if err != nil {
 			http.NotFound(w, r)
 			return
 		}
 
 		rows, err := pool.Query(r.Context(), `
-	SELECT kind, label, set_index, value_int, checked
+	SELECT kind, label, set_index, value_int, checked, superset, planned_label
 	FROM workout_items
 	WHERE workout_id=$1
 	ORDER BY kind, label, set_index
@@ -682,17 +845,23 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 			Checked bool
 		}
 		type setRow struct {
-			Label  string
-			Values []int
+			Label    string
+			Values   []int
+			Rests    []int // seconds rested after each set, as timed
+			Superset string
+			Planned  string // the plan's exercise when this one was swapped in
 		}
 		var checks []checkRow
 		tmpSets := map[string][]int{}
+		supersetOf := map[string]string{}
+		plannedOf := map[string]string{}
 		for rows.Next() {
 			var k, lbl string
 			var si *int32
 			var vi *int32
 			var ch *bool
-			if err := rows.Scan(&k, &lbl, &si, &vi, &ch); err != nil {
+			var ss, pl *string
+			if err := rows.Scan(&k, &lbl, &si, &vi, &ch, &ss, &pl); err != nil {
 				http.Error(w, "db scan error", http.StatusInternalServerError)
 				return
 			}
@@ -701,23 +870,29 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 				checks = append(checks, checkRow{Label: lbl, Checked: checked})
 			} else if k == "sets" && si != nil && vi != nil {
 				tmpSets[lbl] = append(tmpSets[lbl], int(*vi))
+				if ss != nil {
+					supersetOf[lbl] = *ss
+				}
+				if pl != nil {
+					plannedOf[lbl] = *pl
+				}
 			}
 		}
 		if err := rows.Err(); err != nil {
 			http.Error(w, "db rows error", http.StatusInternalServerError)
 			return
 		}
+		rests, err := db.RestsByLabel(r.Context(), pool, id)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
 		var sets []setRow
 		for lbl, vals := range tmpSets {
-			sets = append(sets, setRow{Label: lbl, Values: vals})
+			sets = append(sets, setRow{Label: lbl, Values: vals, Rests: rests[lbl], Superset: supersetOf[lbl], Planned: plannedOf[lbl]})
 		}
 
-		var dateStr string
-		if sd != nil {
-			dateStr = sd.In(loc).Format("2006-01-02")
-		} else if ct != nil {
-			dateStr = ct.In(loc).Format("2006-01-02")
-		}
+		dateStr := workoutDate(sd, ct, loc)
 		var bwStr string
 		if bw != nil {
 			bwStr = fmt.Sprintf("%.2f", *bw)
@@ -730,17 +905,41 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 				Date       string
 				Completed  bool
 				BodyWeight string
-			}
-			Checks []checkRow
-			Sets   []setRow
+				Notes      string
+				Started    string
+				Ended      string
+				Duration   string
+			}
+			Checks   []checkRow
+			Sets     []setRow
+			Circuits []db.CircuitRounds
+			History  []historyRow
 		}{}
 		data.W.ID = id
 		data.W.DayNum = day
 		data.W.Date = dateStr
 		data.W.Completed = ct != nil
 		data.W.BodyWeight = bwStr
+		if st != nil {
+			data.W.Started = st.In(loc).Format("2006-01-02 15:04")
+		}
+		if et != nil {
+			data.W.Ended = et.In(loc).Format("2006-01-02 15:04")
+		}
+		data.W.Duration = durationText(st, et)
+		if notes != nil {
+			data.W.Notes = *notes
+		}
 		data.Checks = checks
 		data.Sets = sets
+		if data.Circuits, err = db.Circuits(r.Context(), pool, id); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if data.History, err = loadHistory(r.Context(), pool, id); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
 
 		t := mustTpl("web/templates/base.gohtml", "web/templates/session_show.gohtml")
 		t = t.Funcs(template.FuncMap{"join": join})
@@ -752,7 +951,7 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 
 	srv := &http.Server{
 		Addr:              "127.0.0.1:8082", // bind to loopback only for reverse proxy
-		Handler:           mux,
+		Handler:           csrfProtect(mux),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 	log.Println("listening on http://127.0.0.1:8082")
@@ -761,6 +960,6 @@ ORDER BY w.id DESC, wi.label NULLS LAST, wi.set_index NULLS LAST;
 
 func mustTpl(files ...string) *template.Template {
 	t := template.New(filepath.Base(files[0]))
-	t = t.Funcs(template.FuncMap{"seq": seq, "join": join})
+	t = t.Funcs(template.FuncMap{"seq": seq, "join": join, "pathEscape": url.PathEscape, "signed": signed})
 	return template.Must(t.ParseFiles(files...))
 }
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/db"
+	"traininglog/internal/plan"
+)
+
+// notifier delivers a reminder somewhere the user will see it.
+type notifier interface {
+	Notify(ctx context.Context, title, body string) error
+}
+
+// notifiers sends through each notifier in turn.
+type notifiers []notifier
+
+func (ns notifiers) Notify(ctx context.Context, title, body string) error {
+	var errs []error
+	for _, n := range ns {
+		errs = append(errs, n.Notify(ctx, title, body))
+	}
+	return errors.Join(errs...)
+}
+
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// post sends req and fails on anything but a 2xx answer.
+func post(req *http.Request) error {
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// smtpNotifier mails the reminder.
+type smtpNotifier struct {
+	addr     string // host:port
+	from     string
+	to       []string
+	user     string
+	password string
+}
+
+// smtpTimeout bounds a whole SMTP conversation, so a server that never
+// answers can't hold up the reminders.
+const smtpTimeout = 30 * time.Second
+
+func (n smtpNotifier) Notify(ctx context.Context, title, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	host, _, _ := net.SplitHostPort(n.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	// as smtp.SendMail does: STARTTLS when offered, then AUTH
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.user != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.user, n.password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	if _, err := io.WriteString(wc, msg.String()); err != nil {
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// webhookNotifier POSTs {"title": ..., "message": ...} as JSON.
+type webhookNotifier struct{ url string }
+
+func (n webhookNotifier) Notify(ctx context.Context, title, body string) error {
+	b, err := json.Marshal(map[string]string{"title": title, "message": body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return post(req)
+}
+
+// ntfyNotifier publishes to an ntfy topic URL such as
+// https://ntfy.sh/my-training: the body is the message, the title a header.
+type ntfyNotifier struct {
+	url   string
+	token string
+}
+
+func (n ntfyNotifier) Notify(ctx context.Context, title, body string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", mime.QEncoding.Encode("utf-8", title)) // ntfy decodes RFC 2047
+	req.Header.Set("Tags", "calendar")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	return post(req)
+}
+
+// loadNotifier builds the notifiers named in REMINDER_NOTIFY, a
+// comma-separated list of smtp, webhook and ntfy. Unset, reminders are off
+// and it returns nil.
+//
+//	smtp:    SMTP_ADDR (host:port), SMTP_FROM, SMTP_TO (comma-separated),
+//	         optional SMTP_USER and SMTP_PASSWORD
+//	webhook: REMINDER_WEBHOOK_URL
+//	ntfy:    NTFY_URL (the topic URL), optional NTFY_TOKEN
+func loadNotifier() (notifier, error) {
+	var ns notifiers
+	for _, kind := range strings.Split(os.Getenv("REMINDER_NOTIFY"), ",") {
+		switch kind = strings.ToLower(strings.TrimSpace(kind)); kind {
+		case "":
+		case "smtp":
+			n := smtpNotifier{
+				addr:     os.Getenv("SMTP_ADDR"),
+				from:     os.Getenv("SMTP_FROM"),
+				user:     os.Getenv("SMTP_USER"),
+				password: os.Getenv("SMTP_PASSWORD"),
+			}
+			for _, to := range strings.Split(os.Getenv("SMTP_TO"), ",") {
+				if to = strings.TrimSpace(to); to != "" {
+					n.to = append(n.to, to)
+				}
+			}
+			if n.addr == "" || n.from == "" || len(n.to) == 0 {
+				return nil, errors.New("smtp needs SMTP_ADDR, SMTP_FROM and SMTP_TO")
+			}
+			ns = append(ns, n)
+		case "webhook":
+			u := os.Getenv("REMINDER_WEBHOOK_URL")
+			if u == "" {
+				return nil, errors.New("webhook needs REMINDER_WEBHOOK_URL")
+			}
+			ns = append(ns, webhookNotifier{url: u})
+		case "ntfy":
+			u := os.Getenv("NTFY_URL")
+			if u == "" {
+				return nil, errors.New("ntfy needs NTFY_URL")
+			}
+			ns = append(ns, ntfyNotifier{url: u, token: os.Getenv("NTFY_TOKEN")})
+		default:
+			return nil, fmt.Errorf("unknown notifier %q in REMINDER_NOTIFY (want smtp, webhook or ntfy)", kind)
+		}
+	}
+	if len(ns) == 0 {
+		return nil, nil
+	}
+	return ns, nil
+}
+
+// loadReminderTime reads REMINDER_TIME ("18:00", local time), the time of
+// day a training day without a session gets its reminder. Default 18:00.
+func loadReminderTime() time.Duration {
+	t, err := time.Parse("15:04", os.Getenv("REMINDER_TIME"))
+	if err != nil {
+		return 18 * time.Hour
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// nextReminder is the first reminder time after now.
+func nextReminder(now time.Time, at time.Duration) time.Time {
+	h, m := int(at/time.Hour), int(at%time.Hour/time.Minute)
+	t := time.Date(now.Year(), now.Month(), now.Day(), h, m, 0, 0, now.Location())
+	if !t.After(now) {
+		t = time.Date(now.Year(), now.Month(), now.Day()+1, h, m, 0, 0, now.Location())
+	}
+	return t
+}
+
+// remind sends today's reminder if today is a training day, no session has
+// been started and it wasn't sent yet. It reports whether one was sent.
+func remind(ctx context.Context, pool *pgxpool.Pool, n notifier) (bool, error) {
+	loc := loadLoc()
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if !loadSchedule().On(today) {
+		return false, nil
+	}
+	started, err := db.StartedOn(ctx, pool, today)
+	if err != nil || started {
+		return false, err
+	}
+	if ok, err := db.ClaimReminder(ctx, pool, today); err != nil || !ok {
+		return false, err
+	}
+	next, phase := nextSession(ctx, pool)
+	title := fmt.Sprintf("Training today: Day %d (%s)", next.Day, plan.DayType(next.Day))
+	body := "No session logged yet today.\n\n"
+	if phase.Deload {
+		body += phase.String() + ": lighter sets and reps.\n\n"
+	}
+	body += planDescription(plan.DayIn(next.Day, phase))
+	if err := n.Notify(ctx, title, body); err != nil {
+		// let a later check (or `traininglog remind`) try again
+		if rerr := db.ReleaseReminder(ctx, pool, today); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// runReminders checks once a day at REMINDER_TIME until ctx is done. Started
+// after today's time, it checks at once, so a restart doesn't skip a day.
+func runReminders(ctx context.Context, pool *pgxpool.Pool, n notifier) {
+	at := loadReminderTime()
+	check := func() {
+		if sent, err := remind(ctx, pool, n); err != nil {
+			log.Printf("reminder: %v", err)
+		} else if sent {
+			log.Printf("reminder sent for %s", time.Now().In(loadLoc()).Format("2006-01-02"))
+		}
+	}
+	now := time.Now().In(loadLoc())
+	if nextReminder(now, at).Day() != now.Day() {
+		check()
+	}
+	for {
+		next := nextReminder(time.Now().In(loadLoc()), at)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		check()
+	}
+}
+
+// traininglog remind [test]  — sends today's reminder now if one is due;
+// with "test", sends a test notification whatever the day.
+func runRemind(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	const usage = "usage: traininglog remind [test]"
+	if len(args) > 1 || (len(args) == 1 && args[0] != "test") {
+		return errors.New(usage)
+	}
+	n, err := loadNotifier()
+	if err != nil {
+		return err
+	}
+	if n == nil {
+		return errors.New("no notifier configured; set REMINDER_NOTIFY")
+	}
+	if len(args) == 1 {
+		return n.Notify(ctx, "Training log test", "Reminders are set up.")
+	}
+	sent, err := remind(ctx, pool, n)
+	if err == nil && !sent {
+		log.Println("no reminder due: not a training day, a session is already started, or it was sent")
+	}
+	return err
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP answers one SMTP conversation on a local port without STARTTLS
+// and sends what it was told on the channel: the commands, then the message.
+func fakeSMTP(t *testing.T) (addr string, got <-chan []string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	ch := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		var lines []string
+		defer func() { ch <- lines }()
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			cmd, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			lines = append(lines, cmd)
+			switch verb := strings.ToUpper(strings.Fields(cmd)[0]); verb {
+			case "EHLO":
+				tp.PrintfLine("250-localhost\r\n250 AUTH PLAIN")
+			case "AUTH":
+				tp.PrintfLine("235 ok")
+			case "MAIL", "RCPT":
+				tp.PrintfLine("250 ok")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				msg, err := tp.ReadDotLines()
+				if err != nil {
+					return
+				}
+				lines = append(lines, strings.Join(msg, "\n"))
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("502 %s?", verb)
+			}
+		}
+	}()
+	return l.Addr().String(), ch
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, got := fakeSMTP(t)
+	n := smtpNotifier{addr: addr, from: "log@example.org", to: []string{"a@example.org", "b@example.org"},
+		user: "log", password: "s3cret"}
+	if err := n.Notify(context.Background(), "Training today: Day 3 (strength B) – go", "line one\nline two"); err != nil {
+		t.Fatal(err)
+	}
+	lines := <-got
+	auth := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00log\x00s3cret"))
+	want := []string{auth, "MAIL FROM:<log@example.org>", "RCPT TO:<a@example.org>", "RCPT TO:<b@example.org>", "DATA"}
+	if len(lines) != len(want)+3 || !strings.HasPrefix(lines[0], "EHLO ") || lines[len(lines)-1] != "QUIT" {
+		t.Fatalf("conversation = %q", lines)
+	}
+	for i, w := range want {
+		if l := lines[i+1]; !strings.HasPrefix(l, w) {
+			t.Errorf("command %d = %q, want %q", i+1, l, w)
+		}
+	}
+
+	msg := lines[len(lines)-2]
+	head, body, _ := strings.Cut(msg, "\n\n")
+	if body != "line one\nline two" {
+		t.Errorf("body = %q", body)
+	}
+	var subject string
+	for _, h := range strings.Split(head, "\n") {
+		if v, ok := strings.CutPrefix(h, "Subject: "); ok {
+			subject, _ = new(mime.WordDecoder).DecodeHeader(v)
+		}
+	}
+	if subject != "Training today: Day 3 (strength B) – go" {
+		t.Errorf("subject = %q in\n%s", subject, head)
+	}
+	if !strings.Contains(head, "To: a@example.org, b@example.org") {
+		t.Errorf("no To header in\n%s", head)
+	}
+}
+
+func TestSMTPNotifierSilentServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept() // never greets
+		if err == nil {
+			defer conn.Close()
+			io.Copy(io.Discard, conn)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := (smtpNotifier{addr: l.Addr().String(), from: "a@b", to: []string{"c@d"}}).Notify(ctx, "t", "b"); err == nil {
+		t.Fatal("no error from a server that never answers")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("gave up after %v", d)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	if err := (webhookNotifier{url: srv.URL}).Notify(context.Background(), "Training today", "Day 1"); err != nil {
+		t.Fatal(err)
+	}
+	if got["title"] != "Training today" || got["message"] != "Day 1" {
+		t.Errorf("posted %v", got)
+	}
+}
+
+func TestNtfyNotifier(t *testing.T) {
+	var title, tags, auth, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title, _ = new(mime.WordDecoder).DecodeHeader(r.Header.Get("Title"))
+		tags, auth = r.Header.Get("Tags"), r.Header.Get("Authorization")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer srv.Close()
+
+	n := ntfyNotifier{url: srv.URL + "/my-training", token: "tk"}
+	if err := n.Notify(context.Background(), "Training today – Day 2", "easy day"); err != nil {
+		t.Fatal(err)
+	}
+	if title != "Training today – Day 2" || tags != "calendar" || auth != "Bearer tk" || body != "easy day" {
+		t.Errorf("title %q tags %q auth %q body %q", title, tags, auth, body)
+	}
+
+	if err := (ntfyNotifier{url: srv.URL}).Notify(context.Background(), "t", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "" {
+		t.Errorf("Authorization %q without a token", auth)
+	}
+}
+
+func TestNotifiersJoinErrors(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/down" {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	ns := notifiers{webhookNotifier{url: srv.URL + "/down"}, ntfyNotifier{url: srv.URL + "/up"}}
+	err := ns.Notify(context.Background(), "t", "b")
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("err = %v, want the 503", err)
+	}
+	if calls != 2 {
+		t.Errorf("%d notifiers called, want both", calls)
+	}
+	if err := (notifiers{webhookNotifier{url: srv.URL + "/up"}}).Notify(context.Background(), "t", "b"); err != nil {
+		t.Errorf("all delivered: %v", err)
+	}
+}
+
+func TestLoadNotifier(t *testing.T) {
+	for _, k := range []string{"SMTP_ADDR", "SMTP_FROM", "SMTP_TO", "SMTP_USER", "SMTP_PASSWORD", "REMINDER_WEBHOOK_URL", "NTFY_URL", "NTFY_TOKEN"} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("REMINDER_NOTIFY", "")
+	if n, err := loadNotifier(); n != nil || err != nil {
+		t.Errorf("unset: %v, %v", n, err)
+	}
+
+	t.Setenv("REMINDER_NOTIFY", "smtp")
+	if _, err := loadNotifier(); err == nil {
+		t.Error("smtp without SMTP_ADDR accepted")
+	}
+	t.Setenv("REMINDER_NOTIFY", "pager")
+	if _, err := loadNotifier(); err == nil {
+		t.Error("unknown notifier accepted")
+	}
+
+	t.Setenv("REMINDER_NOTIFY", " SMTP , ntfy")
+	t.Setenv("SMTP_ADDR", "mail:587")
+	t.Setenv("SMTP_FROM", "log@example.org")
+	t.Setenv("SMTP_TO", "a@example.org, ,b@example.org")
+	t.Setenv("NTFY_URL", "https://ntfy.sh/t")
+	n, err := loadNotifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns, ok := n.(notifiers)
+	if !ok || len(ns) != 2 {
+		t.Fatalf("notifier = %#v", n)
+	}
+	if s, ok := ns[0].(smtpNotifier); !ok || len(s.to) != 2 || s.to[1] != "b@example.org" {
+		t.Errorf("smtp = %#v", ns[0])
+	}
+	if _, ok := ns[1].(ntfyNotifier); !ok {
+		t.Errorf("second = %#v, want ntfy", ns[1])
+	}
+}
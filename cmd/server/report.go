@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/db"
+)
+
+// reportPeriod is an ISO week or a calendar month.
+type reportPeriod struct {
+	Kind  string    // "week" or "month"
+	Key   string    // "2026-W42" or "2026-10"
+	Start time.Time // local midnight
+	End   time.Time // exclusive
+}
+
+// periodAt is the period of kind containing t.
+func periodAt(kind string, t time.Time) reportPeriod {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if kind == "month" {
+		start := day.AddDate(0, 0, 1-day.Day())
+		return reportPeriod{Kind: kind, Key: start.Format("2006-01"), Start: start, End: start.AddDate(0, 1, 0)}
+	}
+	start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)) // ISO weeks start on Monday
+	y, w := start.ISOWeek()
+	return reportPeriod{Kind: "week", Key: fmt.Sprintf("%d-W%02d", y, w), Start: start, End: start.AddDate(0, 0, 7)}
+}
+
+// parsePeriod reads a period key ("2026-W42" or "2026-10") of kind.
+func parsePeriod(kind, key string, loc *time.Location) (reportPeriod, bool) {
+	switch kind {
+	case "month":
+		t, err := time.ParseInLocation("2006-01", key, loc)
+		if err != nil {
+			return reportPeriod{}, false
+		}
+		return periodAt(kind, t), true
+	case "week":
+		var y, w int
+		if n, err := fmt.Sscanf(key, "%d-W%d", &y, &w); err != nil || n != 2 || w < 1 || w > 53 {
+			return reportPeriod{}, false
+		}
+		// January 4th is always in week 1
+		p := periodAt(kind, time.Date(y, 1, 4, 0, 0, 0, 0, loc).AddDate(0, 0, 7*(w-1)))
+		return p, p.Key == key // also rejects trailing input Sscanf ignores
+	}
+	return reportPeriod{}, false
+}
+
+func (p reportPeriod) Prev() reportPeriod { return periodAt(p.Kind, p.Start.AddDate(0, 0, -1)) }
+func (p reportPeriod) Next() reportPeriod { return periodAt(p.Kind, p.End) }
+
+// Title is "Week 42, 2026 (Oct 12 – Oct 18)" or "October 2026".
+func (p reportPeriod) Title() string {
+	if p.Kind == "month" {
+		return p.Start.Format("January 2006")
+	}
+	y, w := p.Start.ISOWeek()
+	return fmt.Sprintf("Week %d, %d (%s – %s)", w, y, p.Start.Format("Jan 2"), p.End.AddDate(0, 0, -1).Format("Jan 2"))
+}
+
+// exerciseTotal is what was done of one exercise in a period and the one
+// before it. Total is reps, or seconds for timed exercises.
+type exerciseTotal struct {
+	Exercise    string
+	Unit        string
+	Sets, Total int
+	PrevTotal   int
+}
+
+func (e exerciseTotal) Change() int { return e.Total - e.PrevTotal }
+
+// report summarises one period against the one before it.
+type report struct {
+	Period       reportPeriod
+	Sessions     int
+	PrevSessions int
+	Planned      int // training days of the period, up to today, from the first logged session
+	Exercises    []exerciseTotal
+	Weighed      bool    // a body weight was recorded in the period
+	WeightFrom   float64 // last body weight before the period, else its first
+	WeightTo     float64 // last body weight of the period
+	PRs          []db.Record
+}
+
+func (r *report) WeightChange() float64 { return r.WeightTo - r.WeightFrom }
+
+func (r *report) SessionChange() int { return r.Sessions - r.PrevSessions }
+
+// buildReport gathers the report for p; today is the local midnight that
+// ends planning for a running period.
+func buildReport(ctx context.Context, pool *pgxpool.Pool, p reportPeriod, today time.Time) (*report, error) {
+	loc := today.Location()
+	beginning := time.Date(1970, 1, 1, 0, 0, 0, 0, loc)
+	prev := p.Prev()
+	rep := &report{Period: p}
+
+	byDay, err := completedByDay(ctx, pool, loc, beginning, p.End)
+	if err != nil {
+		return nil, err
+	}
+	days := make([]string, 0, len(byDay))
+	for k := range byDay {
+		days = append(days, k)
+	}
+	sort.Strings(days)
+	start := p.Start.Format("2006-01-02")
+	var before *float64 // latest body weight before the period
+	for _, k := range days {
+		for _, s := range byDay[k] {
+			switch {
+			case k >= start:
+				rep.Sessions++
+				if s.BodyWeight != nil {
+					if !rep.Weighed {
+						rep.Weighed, rep.WeightFrom = true, *s.BodyWeight
+						if before != nil {
+							rep.WeightFrom = *before
+						}
+					}
+					rep.WeightTo = *s.BodyWeight
+				}
+			case k >= prev.Start.Format("2006-01-02"):
+				rep.PrevSessions++
+				fallthrough
+			default:
+				if s.BodyWeight != nil {
+					before = s.BodyWeight
+				}
+			}
+		}
+	}
+	if len(days) > 0 {
+		sched := loadSchedule()
+		first, _ := time.ParseInLocation("2006-01-02", days[0], loc)
+		for d := p.Start; d.Before(p.End) && !d.After(today); d = d.AddDate(0, 0, 1) {
+			if !d.Before(first) && sched.On(d) {
+				rep.Planned++
+			}
+		}
+	}
+
+	// sets of this period and the one before; db.Records finds the PRs
+	sets, err := db.LoggedSets(ctx, pool, prev.Start, p.End)
+	if err != nil {
+		return nil, err
+	}
+	totals := map[string]*exerciseTotal{}
+	for _, s := range sets {
+		k := s.Day(loc)
+		t, ok := totals[s.Exercise]
+		if !ok {
+			t = &exerciseTotal{Exercise: s.Exercise, Unit: s.Unit}
+			totals[s.Exercise] = t
+		}
+		if k < start {
+			t.PrevTotal += s.Value
+			continue
+		}
+		t.Sets++
+		t.Total += s.Value
+	}
+	for _, t := range totals {
+		rep.Exercises = append(rep.Exercises, *t)
+	}
+	sort.Slice(rep.Exercises, func(i, j int) bool { return rep.Exercises[i].Exercise < rep.Exercises[j].Exercise })
+
+	if rep.PRs, err = db.Records(ctx, pool, p.Start, p.End); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// signed renders n with an explicit sign, e.g. "+3" or "-1"; "±0" for zero.
+func signed(n int) string {
+	if n == 0 {
+		return "±0"
+	}
+	return fmt.Sprintf("%+d", n)
+}
+
+// writeReportMarkdown renders rep as a Markdown digest.
+func writeReportMarkdown(w io.Writer, rep *report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", rep.Period.Title())
+	fmt.Fprintf(&b, "- Sessions: %d of %d planned (%s vs previous %s)\n", rep.Sessions, rep.Planned, signed(rep.SessionChange()), rep.Period.Kind)
+	if rep.Weighed {
+		fmt.Fprintf(&b, "- Body weight: %.1f → %.1f kg (%+.1f)\n", rep.WeightFrom, rep.WeightTo, rep.WeightChange())
+	} else {
+		b.WriteString("- Body weight: not recorded\n")
+	}
+
+	b.WriteString("\n## Exercises\n\n")
+	if len(rep.Exercises) == 0 {
+		b.WriteString("Nothing logged.\n")
+	} else {
+		b.WriteString("| Exercise | Sets | Total | Change |\n|---|---:|---:|---:|\n")
+		for _, e := range rep.Exercises {
+			fmt.Fprintf(&b, "| %s | %d | %d %s | %s |\n", e.Exercise, e.Sets, e.Total, e.Unit, signed(e.Change()))
+		}
+	}
+
+	b.WriteString("\n## PRs\n\n")
+	if len(rep.PRs) == 0 {
+		b.WriteString("None this time.\n")
+	}
+	for _, pr := range rep.PRs {
+		fmt.Fprintf(&b, "- %s: %d %s (was %d) on %s\n", pr.Exercise, pr.Value, pr.Unit, pr.Previous, pr.Date)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// GET /reports redirects to the current week's report.
+func handleReportsIndex(w http.ResponseWriter, r *http.Request) {
+	p := periodAt("week", time.Now().In(loadLoc()))
+	http.Redirect(w, r, "/reports/week/"+p.Key, http.StatusSeeOther)
+}
+
+// GET /reports/{kind}/{key}  kind is "week" or "month"
+func handleReport(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loc := loadLoc()
+		p, ok := parsePeriod(r.PathValue("kind"), r.PathValue("key"), loc)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		now := time.Now().In(loc)
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		rep, err := buildReport(r.Context(), pool, p, today)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		data := struct {
+			*report
+			Week, Month reportPeriod // the other kind of report for the same start
+			HasNext     bool
+		}{report: rep, Week: periodAt("week", p.Start), Month: periodAt("month", p.Start), HasNext: !p.End.After(today)}
+
+		t := mustTpl("web/templates/base.gohtml", "web/templates/report.gohtml")
+		if err := t.ExecuteTemplate(w, "base.gohtml", data); err != nil {
+			http.Error(w, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// traininglog report week|month [key]  — prints the Markdown digest of the
+// given period, or the current one.
+func runReport(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	const usage = "usage: traininglog report week|month [2026-W42|2026-10]"
+	if len(args) < 1 || len(args) > 2 || (args[0] != "week" && args[0] != "month") {
+		return errors.New(usage)
+	}
+	loc := loadLoc()
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	p := periodAt(args[0], today)
+	if len(args) == 2 {
+		var ok bool
+		if p, ok = parsePeriod(args[0], args[1], loc); !ok {
+			return fmt.Errorf("bad %s %q; %s", args[0], args[1], usage)
+		}
+	}
+	rep, err := buildReport(ctx, pool, p, today)
+	if err != nil {
+		return err
+	}
+	return writeReportMarkdown(os.Stdout, rep)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodAt(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		kind, at   string
+		key, start string
+	}{
+		{"week", "2026-01-01", "2026-W01", "2025-12-29"}, // week 1 starts in December
+		{"week", "2025-12-28", "2025-W52", "2025-12-22"},
+		{"week", "2027-01-03", "2026-W53", "2026-12-28"}, // week 53 ends in January
+		{"week", "2027-01-04", "2027-W01", "2027-01-04"},
+		{"week", "2021-01-03", "2020-W53", "2020-12-28"},
+		{"month", "2026-12-31", "2026-12", "2026-12-01"},
+		{"month", "2027-01-01", "2027-01", "2027-01-01"},
+	}
+	for _, tt := range tests {
+		p := periodAt(tt.kind, day(tt.at).Add(15*time.Hour))
+		if p.Key != tt.key || !p.Start.Equal(day(tt.start)) {
+			t.Errorf("periodAt(%s, %s) = %s from %s, want %s from %s",
+				tt.kind, tt.at, p.Key, p.Start.Format("2006-01-02"), tt.key, tt.start)
+		}
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		kind, key string
+		ok        bool
+		prev      string
+		next      string
+	}{
+		{"week", "2026-W01", true, "2025-W52", "2026-W02"},
+		{"week", "2026-W53", true, "2026-W52", "2027-W01"},
+		{"week", "2020-W53", true, "2020-W52", "2021-W01"},
+		{"week", "2025-W53", false, "", ""}, // 2025 has 52 weeks
+		{"week", "2026-W00", false, "", ""},
+		{"week", "2026-W1", false, "", ""},
+		{"week", "2026-W42junk", false, "", ""},
+		{"week", "2026-10", false, "", ""},
+		{"month", "2026-01", true, "2025-12", "2026-02"},
+		{"month", "2026-12", true, "2026-11", "2027-01"},
+		{"month", "2026-13", false, "", ""},
+		{"month", "2026-W42", false, "", ""},
+		{"year", "2026", false, "", ""},
+	}
+	for _, tt := range tests {
+		p, ok := parsePeriod(tt.kind, tt.key, time.UTC)
+		if ok != tt.ok {
+			t.Errorf("parsePeriod(%s, %q) ok = %v, want %v", tt.kind, tt.key, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if p.Key != tt.key || p.Prev().Key != tt.prev || p.Next().Key != tt.next {
+			t.Errorf("parsePeriod(%s, %q) = %s (prev %s, next %s), want %s (prev %s, next %s)",
+				tt.kind, tt.key, p.Key, p.Prev().Key, p.Next().Key, tt.key, tt.prev, tt.next)
+		}
+	}
+}
+
+func TestPeriodTitle(t *testing.T) {
+	p, _ := parsePeriod("week", "2026-W01", time.UTC)
+	if got, want := p.Title(), "Week 1, 2026 (Dec 29 – Jan 4)"; got != want {
+		t.Errorf("Title() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"traininglog/internal/plan"
+)
+
+// schedule marks the weekdays training is planned on, indexed by
+// time.Weekday.
+type schedule [7]bool
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// loadSchedule reads TRAINING_DAYS, comma-separated weekdays such as
+// "mon,wed,fri" (full names work too). Unset, or with no valid day in it,
+// every day is a training day, as the rotation was written for.
+func loadSchedule() schedule {
+	var s schedule
+	found := false
+	for _, f := range strings.Split(os.Getenv("TRAINING_DAYS"), ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if len(f) < 3 {
+			continue
+		}
+		if wd, ok := weekdayNames[f[:3]]; ok {
+			s[wd], found = true, true
+		}
+	}
+	if !found {
+		for i := range s {
+			s[i] = true
+		}
+	}
+	return s
+}
+
+// On reports whether d is a training day.
+func (s schedule) On(d time.Time) bool { return s[d.Weekday()] }
+
+// project lays the rotation onto the training days in [start, end), both
+// local midnights, starting at rotation day day. Keys are "2006-01-02".
+func (s schedule) project(start, end time.Time, day int) map[string]int {
+	out := map[string]int{}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		if s.On(d) {
+			out[d.Format("2006-01-02")] = day
+			day = day%12 + 1
+		}
+	}
+	return out
+}
+
+// markSchedule fills in the training, planned and missed cells. The
+// rotation is projected from today, or tomorrow once today has a session;
+// training days from the first logged session (if any) up to yesterday
+// without a session are missed.
+func markSchedule(cells []DayCell, s schedule, today time.Time, doneToday bool, first *time.Time, nextDay int) {
+	if len(cells) == 0 {
+		return
+	}
+	start := today
+	if doneToday {
+		start = today.AddDate(0, 0, 1)
+	}
+	planned := s.project(start, cells[len(cells)-1].Date.AddDate(0, 0, 1), nextDay)
+	for i := range cells {
+		c := &cells[i]
+		c.Training = s.On(c.Date)
+		if day, ok := planned[c.Date.Format("2006-01-02")]; ok {
+			c.Planned = day
+			c.PlannedType = plan.DayType(day)
+		}
+		c.Missed = c.Training && !c.Completed && first != nil && !c.Date.Before(*first) && c.Date.Before(today)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestLoadSchedule(t *testing.T) {
+	all := schedule{true, true, true, true, true, true, true}
+	tests := []struct {
+		env  string
+		want schedule
+	}{
+		{"", all},
+		{"mon,wed,fri", schedule{time.Monday: true, time.Wednesday: true, time.Friday: true}},
+		{" Tuesday , THURSDAY,sat ", schedule{time.Tuesday: true, time.Thursday: true, time.Saturday: true}},
+		{"sun,mo,x", schedule{time.Sunday: true}},
+		{"weekends", all},
+	}
+	for _, tt := range tests {
+		t.Setenv("TRAINING_DAYS", tt.env)
+		if got := loadSchedule(); got != tt.want {
+			t.Errorf("TRAINING_DAYS=%q: %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestProject(t *testing.T) {
+	s := schedule{time.Monday: true, time.Wednesday: true, time.Friday: true}
+	start := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC) // a Monday
+	got := s.project(start, start.AddDate(0, 0, 14), 11)
+	want := map[string]int{
+		"2026-10-12": 11, "2026-10-14": 12, "2026-10-16": 1, // the rotation wraps after day 12
+		"2026-10-19": 2, "2026-10-21": 3, "2026-10-23": 4,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("project = %v, want %v", got, want)
+	}
+	if got := s.project(start, start, 1); len(got) != 0 {
+		t.Errorf("empty range projected %v", got)
+	}
+}
+
+func TestMarkSchedule(t *testing.T) {
+	s := schedule{time.Monday: true, time.Wednesday: true, time.Friday: true}
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	today, first := day(21), day(13) // Wednesday; first session on Tuesday the 13th
+	cells := func() []DayCell {
+		var cs []DayCell
+		for d := 12; d <= 25; d++ {
+			cs = append(cs, DayCell{Date: day(d), Completed: d == 13 || d == 16})
+		}
+		return cs
+	}
+	at := func(cs []DayCell, d int) DayCell { return cs[d-12] }
+
+	cs := cells()
+	markSchedule(cs, s, today, false, &first, 5)
+	for d := 12; d <= 25; d++ {
+		c := at(cs, d)
+		training := d == 12 || d == 14 || d == 16 || d == 19 || d == 21 || d == 23
+		missed := d == 14 || d == 19 // 12 is before the first session, 16 was done, 21 is today
+		if c.Training != training || c.Missed != missed {
+			t.Errorf("%d: training %v missed %v, want %v %v", d, c.Training, c.Missed, training, missed)
+		}
+	}
+	for d, want := range map[int]struct {
+		planned int
+		typ     string
+	}{19: {}, 21: {5, "strength A"}, 22: {}, 23: {6, "easy"}} {
+		if c := at(cs, d); c.Planned != want.planned || c.PlannedType != want.typ {
+			t.Errorf("%d planned %d %q, want %d %q", d, c.Planned, c.PlannedType, want.planned, want.typ)
+		}
+	}
+
+	// once today has a session the rotation starts tomorrow
+	cs = cells()
+	markSchedule(cs, s, today, true, &first, 5)
+	if c := at(cs, 21); c.Planned != 0 {
+		t.Errorf("today planned %d after a session", c.Planned)
+	}
+	if c := at(cs, 23); c.Planned != 5 || c.PlannedType != "strength A" {
+		t.Errorf("23 planned %d %q, want 5 strength A", c.Planned, c.PlannedType)
+	}
+
+	// nothing logged yet: nothing is missed
+	cs = cells()
+	markSchedule(cs, s, today, false, nil, 1)
+	for _, c := range cs {
+		if c.Missed {
+			t.Errorf("%s missed with no sessions logged", c.Date.Format("2006-01-02"))
+		}
+	}
+
+	markSchedule(nil, s, today, false, &first, 1) // no cells: no panic
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+
+	"traininglog/internal/db"
+	"traininglog/internal/plan"
+)
+
+// Limits for the workout metadata fields.
+const (
+	minBodyWeightKg = 20
+	maxBodyWeightKg = 400
+	maxNotesLen     = 2000
+	maxLabelLen     = 80
+)
+
+// sessionForm is a validated /session/save or /session/complete post.
+type sessionForm struct {
+	WorkoutID   int64
+	ClientUUID  string // generated by the page; ties offline saves to one workout
+	Day         int
+	Deload      bool       // planned in a deload week
+	SessionDate *time.Time // UTC midnight, like pgx returns DATE
+	BodyWeight  *float64
+	StartedAt   *time.Time
+	EndedAt     *time.Time
+	Notes       *string // nil when the form had no notes field
+	Items       []formItem
+	Revision    int // set by persistSession
+
+	// error slots on the page (err-<name>), so a response can clear them
+	slots []string
+}
+
+type formItem struct {
+	Kind    string // "check" or "sets"
+	Label   string // the exercise performed
+	Planned string // the plan's label; differs from Label after a swap
+	Checked bool
+	Sets    []formSet
+}
+
+type formSet struct {
+	Index int
+	Value int
+}
+
+// fieldErrors maps an error slot (a metadata field name or "it_<i>" for an
+// item row) to its message; "" holds errors with no slot on the page.
+type fieldErrors map[string]string
+
+func (fe fieldErrors) add(slot, msg string) {
+	if prev, ok := fe[slot]; ok {
+		fe[slot] = prev + "; " + msg
+		return
+	}
+	fe[slot] = msg
+}
+
+// parseSessionForm reads and validates the whole form before anything is
+// written, so a bad value never leaves a half-saved workout behind.
+func parseSessionForm(r *http.Request) (*sessionForm, fieldErrors) {
+	errs := fieldErrors{}
+	f := &sessionForm{slots: []string{"session_date", "body_weight_kg", "started_at", "ended_at", "notes"}}
+
+	if idStr := r.PostFormValue("workout_id"); idStr != "" {
+		if id, err := strconv.ParseInt(idStr, 10, 64); err == nil && id > 0 {
+			f.WorkoutID = id
+		}
+	}
+	if u := r.PostFormValue("client_uuid"); u != "" {
+		if !validUUID(u) {
+			errs.add("", "invalid client id")
+		}
+		f.ClientUUID = strings.ToLower(u)
+	}
+	day, err := strconv.Atoi(r.PostFormValue("day"))
+	if err != nil || day < 1 || day > 12 {
+		errs.add("", "invalid rotation day")
+	}
+	f.Day = day
+	f.Deload = r.PostFormValue("deload") != ""
+
+	if d := r.PostFormValue("session_date"); d != "" {
+		t, msg := checkSessionDate(d)
+		if msg != "" {
+			errs.add("session_date", msg)
+		}
+		f.SessionDate = t
+	}
+	if bw := r.PostFormValue("body_weight_kg"); bw != "" {
+		v, msg := checkBodyWeight(bw)
+		if msg != "" {
+			errs.add("body_weight_kg", msg)
+		}
+		f.BodyWeight = v
+	}
+	loc := loadLoc()
+	for _, c := range []struct {
+		name string
+		dst  **time.Time
+	}{{"started_at", &f.StartedAt}, {"ended_at", &f.EndedAt}} {
+		if s := r.PostFormValue(c.name); s != "" {
+			t, msg := checkClock(s, loc)
+			if msg != "" {
+				errs.add(c.name, msg)
+			}
+			*c.dst = t
+		}
+	}
+	if msg := checkSpan(f.StartedAt, f.EndedAt); msg != "" {
+		errs.add("ended_at", msg)
+	}
+	if _, ok := r.PostForm["notes"]; ok {
+		n, msg := checkNotes(r.PostFormValue("notes"))
+		if msg != "" {
+			errs.add("notes", msg)
+		}
+		f.Notes = &n
+	}
+
+	// items in page order so messages and inserts are deterministic
+	for _, i := range postedItems(r) {
+		p := "it_" + strconv.Itoa(i)
+		it := formItem{Kind: r.PostFormValue(p + "_kind"), Label: r.PostFormValue(p + "_label"), Planned: r.PostFormValue(p + "_planned")}
+		if it.Planned == "" {
+			it.Planned = it.Label
+		}
+		switch it.Kind {
+		case "heading":
+			continue
+		case "check", "sets":
+		default:
+			errs.add("", fmt.Sprintf("unknown item kind %q", it.Kind))
+			continue
+		}
+		if it.Label == "" {
+			errs.add("", "item without a label")
+			continue
+		}
+		f.slots = append(f.slots, p)
+		if msg := checkLabel(it.Label); msg != "" {
+			errs.add(p, msg)
+			continue
+		}
+
+		if it.Kind == "check" {
+			it.Checked = r.PostFormValue("c_"+strconv.Itoa(i)) != ""
+			f.Items = append(f.Items, it)
+			continue
+		}
+
+		if msg := checkSwap(f.Day, it.Planned, it.Label); msg != "" {
+			errs.add(p, msg)
+			continue
+		}
+		maxSets, maxValue := setLimits(f.Day, f.Deload, it.Planned)
+		sets, err := strconv.Atoi(r.PostFormValue(p + "_sets"))
+		if err != nil || sets < 1 || sets > maxSets {
+			errs.add(p, fmt.Sprintf("set count must be 1–%d", maxSets))
+			continue
+		}
+		for s := 1; s <= sets; s++ {
+			vStr := strings.TrimSpace(r.PostFormValue(fmt.Sprintf("s_%d_%d", i, s)))
+			if vStr == "" {
+				continue
+			}
+			v, msg := checkSetValue(vStr, maxValue)
+			if msg != "" {
+				errs.add(p, fmt.Sprintf("set %d: %s", s, msg))
+				continue
+			}
+			it.Sets = append(it.Sets, formSet{Index: s, Value: v})
+		}
+		f.Items = append(f.Items, it)
+	}
+
+	if len(errs) == 0 {
+		return f, nil
+	}
+	return f, errs
+}
+
+// resolveWorkout locks the workout a form saves to. A form that only knows
+// its client UUID (e.g. the first online save after the page queued offline)
+// gets the workout id filled in; without either a new workout is created
+// later. A workout that is gone or in the trash is errNoWorkout.
+func resolveWorkout(ctx context.Context, tx pgx.Tx, f *sessionForm) error {
+	if f.WorkoutID == 0 {
+		if f.ClientUUID == "" {
+			return nil
+		}
+		st, ok, err := db.WorkoutByClientUUID(ctx, tx, f.ClientUUID)
+		if err != nil || !ok {
+			return err
+		}
+		if st.DeletedAt != nil {
+			return errNoWorkout
+		}
+		f.WorkoutID = st.ID
+		return nil
+	}
+	var deleted *time.Time
+	err := tx.QueryRow(ctx, `SELECT deleted_at FROM workouts WHERE id=$1 FOR UPDATE`, f.WorkoutID).Scan(&deleted)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && deleted != nil) {
+		return errNoWorkout
+	}
+	return err
+}
+
+// goneMessage answers a save, autosave or completion of a workout that is
+// gone or in the trash.
+const goneMessage = "This workout was deleted; restore it from the trash to keep editing."
+
+// The check* helpers validate one form value; a non-empty message means the
+// value was rejected.
+
+func checkSessionDate(s string) (*time.Time, string) {
+	t, err := time.Parse("2006-01-02", s)
+	switch {
+	case err != nil:
+		return nil, "not a date"
+	case t.Year() < 2000 || t.After(time.Now().AddDate(0, 0, 1)):
+		return nil, "date out of range"
+	}
+	return &t, ""
+}
+
+func checkBodyWeight(s string) (*float64, string) {
+	v, err := strconv.ParseFloat(s, 64)
+	switch {
+	case err != nil:
+		return nil, "not a number"
+	case v < minBodyWeightKg || v > maxBodyWeightKg:
+		return nil, fmt.Sprintf("must be %d–%d kg", minBodyWeightKg, maxBodyWeightKg)
+	}
+	return &v, ""
+}
+
+// checkClock reads a datetime-local value ("2006-01-02T15:04") in loc.
+func checkClock(s string, loc *time.Location) (*time.Time, string) {
+	t, err := time.ParseInLocation("2006-01-02T15:04", s, loc)
+	switch {
+	case err != nil:
+		return nil, "not a date and time"
+	case t.Year() < 2000 || t.After(time.Now().Add(24*time.Hour)):
+		return nil, "out of range"
+	}
+	return &t, ""
+}
+
+// checkSpan rejects an end before the start or a session too long to be real.
+func checkSpan(st, et *time.Time) string {
+	switch {
+	case st == nil || et == nil:
+		return ""
+	case et.Before(*st):
+		return "ends before it starts"
+	case et.Sub(*st) > maxSessionLength:
+		return fmt.Sprintf("longer than %d hours", int(maxSessionLength.Hours()))
+	}
+	return ""
+}
+
+func checkNotes(s string) (string, string) {
+	n := strings.TrimSpace(s)
+	if utf8.RuneCountInString(n) > maxNotesLen {
+		return n, fmt.Sprintf("at most %d characters", maxNotesLen)
+	}
+	return n, ""
+}
+
+func checkSetValue(s string, maxValue int) (int, string) {
+	v, err := strconv.Atoi(s)
+	switch {
+	case err != nil:
+		return 0, "whole numbers only"
+	case v < 0:
+		return 0, "can't be negative"
+	case v > maxValue:
+		return 0, fmt.Sprintf("over %d, typo?", maxValue)
+	}
+	return v, ""
+}
+
+// checkLabel rejects exercise names the form could never have produced.
+func checkLabel(s string) string {
+	switch {
+	case strings.TrimSpace(s) == "":
+		return "name the exercise"
+	case utf8.RuneCountInString(s) > maxLabelLen:
+		return fmt.Sprintf("at most %d characters", maxLabelLen)
+	}
+	return ""
+}
+
+// checkSwap accepts performed in place of the planned item only if the plan
+// offers it as a substitute.
+func checkSwap(day int, planned, performed string) string {
+	if performed == planned {
+		return ""
+	}
+	if pi, ok := plan.Find(day, planned); ok && plan.CanSwap(pi, performed) {
+		return ""
+	}
+	return fmt.Sprintf("%q can't replace %q", performed, planned)
+}
+
+// setLimits is how many sets, and how big a value per set, label accepts on
+// rotation day day, planned in a deload week or not. Planned items may run a
+// few sets past the plan; labels the plan doesn't know are exercises added
+// during the session.
+func setLimits(day int, deload bool, label string) (maxSets, maxValue int) {
+	for _, pi := range planFor(day, deload) {
+		if pi.Label == label && pi.Kind == "sets" {
+			return pi.Sets + plan.MaxExtraSets, pi.MaxValue()
+		}
+	}
+	return plan.UnplannedMaxSets, plan.UnplannedMaxValue
+}
+
+// persistSession writes a validated form inside tx, creating the workout if
+// the form had no id yet, and bumps the workout's revision. Items are
+// replaced label by label.
+func persistSession(ctx context.Context, tx pgx.Tx, f *sessionForm) (created bool, err error) {
+	if f.WorkoutID == 0 {
+		var uuid *string
+		if f.ClientUUID != "" {
+			uuid = &f.ClientUUID
+		}
+		if err := tx.QueryRow(ctx, `INSERT INTO workouts(day_num, client_uuid, deload) VALUES ($1, $2::uuid, $3) RETURNING id`, f.Day, uuid, f.Deload).Scan(&f.WorkoutID); err != nil {
+			return false, err
+		}
+		created = true
+	} else if err := tx.QueryRow(ctx, `SELECT deload FROM workouts WHERE id=$1`, f.WorkoutID).Scan(&f.Deload); err != nil {
+		return false, err // a workout keeps the phase it was started in
+	}
+
+	if f.SessionDate != nil {
+		if _, err := tx.Exec(ctx, `UPDATE workouts SET session_date=$2 WHERE id=$1`, f.WorkoutID, *f.SessionDate); err != nil {
+			return created, err
+		}
+	}
+	if f.BodyWeight != nil {
+		if _, err := tx.Exec(ctx, `UPDATE workouts SET body_weight_kg=$2 WHERE id=$1`, f.WorkoutID, *f.BodyWeight); err != nil {
+			return created, err
+		}
+	}
+	if f.StartedAt != nil {
+		if _, err := tx.Exec(ctx, `UPDATE workouts SET started_at=$2 WHERE id=$1`, f.WorkoutID, *f.StartedAt); err != nil {
+			return created, err
+		}
+	}
+	if f.EndedAt != nil {
+		if _, err := tx.Exec(ctx, `UPDATE workouts SET ended_at=$2 WHERE id=$1`, f.WorkoutID, *f.EndedAt); err != nil {
+			return created, err
+		}
+	}
+	if f.Notes != nil {
+		if _, err := tx.Exec(ctx, `UPDATE workouts SET notes=NULLIF($2, '') WHERE id=$1`, f.WorkoutID, *f.Notes); err != nil {
+			return created, err
+		}
+	}
+
+	for _, it := range f.Items {
+		// by planned label, so rows logged before a swap are replaced too
+		if _, err := tx.Exec(ctx, `DELETE FROM workout_items WHERE workout_id=$1 AND COALESCE(planned_label, label)=$2`, f.WorkoutID, it.Planned); err != nil {
+			return created, err
+		}
+		switch it.Kind {
+		case "check":
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO workout_items(workout_id,kind,label,checked) VALUES ($1,'check',$2,$3)`,
+				f.WorkoutID, it.Label, it.Checked,
+			); err != nil {
+				return created, err
+			}
+		case "sets":
+			for _, s := range it.Sets {
+				if _, err := tx.Exec(ctx,
+					`INSERT INTO workout_items(workout_id,kind,label,set_index,value_int,planned_label) VALUES ($1,'sets',$2,$3,$4,NULLIF($5,$2))`,
+					f.WorkoutID, it.Label, s.Index, s.Value, it.Planned,
+				); err != nil {
+					return created, err
+				}
+			}
+		}
+	}
+	if err := db.LinkExercises(ctx, tx, f.WorkoutID); err != nil {
+		return created, err
+	}
+	if err := syncGroups(ctx, tx, f.WorkoutID, f.Day, f.Deload); err != nil {
+		return created, err
+	}
+	f.Revision, err = touchWorkout(ctx, tx, f.WorkoutID)
+	return created, err
+}
+
+// validUUID accepts the canonical 8-4-4-4-12 hex form.
+func validUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type errorSlot struct {
+	Name string
+	Msg  string
+}
+
+// writeFieldErrors renders message into the request's target and swaps every
+// error slot out of band: filled where errs has a message, emptied elsewhere.
+// With errs it answers 422, which base.gohtml tells htmx to swap.
+func writeFieldErrors(w http.ResponseWriter, f *sessionForm, errs fieldErrors, message string) {
+	data := struct {
+		Message string
+		General string
+		Slots   []errorSlot
+	}{Message: message, General: errs[""]}
+	for _, s := range f.slots {
+		data.Slots = append(data.Slots, errorSlot{Name: s, Msg: errs[s]})
+	}
+	t := mustTpl("web/templates/field_errors.gohtml")
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	if err := t.ExecuteTemplate(w, "field_errors.gohtml", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
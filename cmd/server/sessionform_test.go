@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func postForm(v url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/session/save", strings.NewReader(v.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ParseForm()
+	return r
+}
+
+// dayOneForm is a valid day 1 save: a ticked check item and two sets of
+// pushups.
+func dayOneForm() url.Values {
+	return url.Values{
+		"day":        {"1"},
+		"it_0_kind":  {"check"},
+		"it_0_label": {"foam roll"},
+		"c_0":        {"on"},
+		"it_3_kind":  {"heading"},
+		"it_3_label": {"3-4 circuits"},
+		"it_4_kind":  {"sets"},
+		"it_4_label": {"inc 2 pushups"},
+		"it_4_sets":  {"4"},
+		"s_4_1":      {"12"},
+		"s_4_2":      {" 10 "},
+	}
+}
+
+func TestParseSessionForm(t *testing.T) {
+	f, errs := parseSessionForm(postForm(dayOneForm()))
+	if errs != nil {
+		t.Fatalf("errors %v", errs)
+	}
+	want := []formItem{
+		{Kind: "check", Label: "foam roll", Planned: "foam roll", Checked: true},
+		{Kind: "sets", Label: "inc 2 pushups", Planned: "inc 2 pushups", Sets: []formSet{{1, 12}, {2, 10}}},
+	}
+	if f.Day != 1 || !reflect.DeepEqual(f.Items, want) {
+		t.Errorf("day %d items %+v, want day 1 items %+v", f.Day, f.Items, want)
+	}
+	if f.Notes != nil || f.BodyWeight != nil || f.SessionDate != nil {
+		t.Errorf("unposted fields set: %+v", f)
+	}
+}
+
+func TestParseSessionFormErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(url.Values)
+		want fieldErrors
+	}{
+		{"bad day", func(v url.Values) { v.Set("day", "13") }, fieldErrors{"": "invalid rotation day"}},
+		{"bad uuid", func(v url.Values) { v.Set("client_uuid", "nope") }, fieldErrors{"": "invalid client id"}},
+		{"date", func(v url.Values) { v.Set("session_date", "2026-13-01") }, fieldErrors{"session_date": "not a date"}},
+		{"old date", func(v url.Values) { v.Set("session_date", "1999-12-31") }, fieldErrors{"session_date": "date out of range"}},
+		{"weight", func(v url.Values) { v.Set("body_weight_kg", "heavy") }, fieldErrors{"body_weight_kg": "not a number"}},
+		{"light", func(v url.Values) { v.Set("body_weight_kg", "19.9") }, fieldErrors{"body_weight_kg": "must be 20–400 kg"}},
+		{"clock", func(v url.Values) { v.Set("started_at", "noon") }, fieldErrors{"started_at": "not a date and time"}},
+		{"backwards", func(v url.Values) {
+			v.Set("started_at", "2026-10-18T10:00")
+			v.Set("ended_at", "2026-10-18T09:00")
+		}, fieldErrors{"ended_at": "ends before it starts"}},
+		{"notes", func(v url.Values) { v.Set("notes", strings.Repeat("é", maxNotesLen+1)) }, fieldErrors{"notes": "at most 2000 characters"}},
+		{"kind", func(v url.Values) { v.Set("it_0_kind", "bogus") }, fieldErrors{"": `unknown item kind "bogus"`}},
+		{"no label", func(v url.Values) { v.Set("it_0_label", "") }, fieldErrors{"": "item without a label"}},
+		{"long label", func(v url.Values) { v.Set("it_0_label", strings.Repeat("x", maxLabelLen+1)) }, fieldErrors{"it_0": "at most 80 characters"}},
+		{"set count", func(v url.Values) { v.Set("it_4_sets", "8") }, fieldErrors{"it_4": "set count must be 1–7"}},
+		{"set values", func(v url.Values) {
+			v.Set("s_4_1", "x")
+			v.Set("s_4_2", "-1")
+			v.Set("s_4_3", "61")
+		}, fieldErrors{"it_4": "set 1: whole numbers only; set 2: can't be negative; set 3: over 60, typo?"}},
+		{"swap", func(v url.Values) {
+			v.Set("it_4_planned", "inc 2 pushups")
+			v.Set("it_4_label", "bw squats")
+		}, fieldErrors{"it_4": `"bw squats" can't replace "inc 2 pushups"`}},
+		{"errors in two places", func(v url.Values) {
+			v.Set("body_weight_kg", "500")
+			v.Set("s_4_1", "999")
+		}, fieldErrors{"body_weight_kg": "must be 20–400 kg", "it_4": "set 1: over 60, typo?"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := dayOneForm()
+			tt.edit(v)
+			_, errs := parseSessionForm(postForm(v))
+			if !reflect.DeepEqual(errs, tt.want) {
+				t.Errorf("errors %q, want %q", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSessionFormSlots(t *testing.T) {
+	f, _ := parseSessionForm(postForm(dayOneForm()))
+	want := []string{"session_date", "body_weight_kg", "started_at", "ended_at", "notes", "it_0", "it_4"}
+	if !reflect.DeepEqual(f.slots, want) {
+		t.Errorf("slots %v, want %v", f.slots, want)
+	}
+}
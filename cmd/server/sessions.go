@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sessionsPageSize is how many rows /sessions loads per request.
+const sessionsPageSize = 50
+
+// sessionFilter is the /sessions query string: filters, text search and the
+// keyset cursor of the last row already shown.
+type sessionFilter struct {
+	Status   string // "", "completed" or "draft"
+	Day      int    // 0 = any
+	From     string // inclusive, 2006-01-02
+	To       string // inclusive, 2006-01-02
+	Exercise string // exact workout_items label
+	Q        string // substring of any label or the notes
+
+	deleted int64 // just-deleted workout to offer an undo for
+
+	afterDate string // cursor: sort date of the last row shown
+	afterID   int64  // cursor: id of the last row shown
+}
+
+func parseSessionFilter(q url.Values) sessionFilter {
+	f := sessionFilter{
+		Status:   q.Get("status"),
+		Exercise: q.Get("exercise"),
+		Q:        strings.TrimSpace(q.Get("q")),
+	}
+	if f.Status != "completed" && f.Status != "draft" {
+		f.Status = ""
+	}
+	if d, err := strconv.Atoi(q.Get("day")); err == nil && d >= 1 && d <= 12 {
+		f.Day = d
+	}
+	if _, err := time.Parse("2006-01-02", q.Get("from")); err == nil {
+		f.From = q.Get("from")
+	}
+	if _, err := time.Parse("2006-01-02", q.Get("to")); err == nil {
+		f.To = q.Get("to")
+	}
+	if id, err := strconv.ParseInt(q.Get("deleted"), 10, 64); err == nil && id > 0 {
+		f.deleted = id
+	}
+	// cursor format: 2006-01-02_<id>
+	if d, id, ok := strings.Cut(q.Get("after"), "_"); ok {
+		if _, err := time.Parse("2006-01-02", d); err == nil {
+			if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+				f.afterDate, f.afterID = d, n
+			}
+		}
+	}
+	return f
+}
+
+// values encodes the filters (not the cursor) back into a query string.
+func (f sessionFilter) values() url.Values {
+	v := url.Values{}
+	if f.Status != "" {
+		v.Set("status", f.Status)
+	}
+	if f.Day != 0 {
+		v.Set("day", strconv.Itoa(f.Day))
+	}
+	if f.From != "" {
+		v.Set("from", f.From)
+	}
+	if f.To != "" {
+		v.Set("to", f.To)
+	}
+	if f.Exercise != "" {
+		v.Set("exercise", f.Exercise)
+	}
+	if f.Q != "" {
+		v.Set("q", f.Q)
+	}
+	return v
+}
+
+// nextURL is the request that loads the page after the row (date, id).
+func (f sessionFilter) nextURL(date string, id int64) string {
+	v := f.values()
+	v.Set("after", fmt.Sprintf("%s_%d", date, id))
+	return "/sessions?" + v.Encode()
+}
+
+// sessionSortDate is the day a workout sorts under in the list: the session
+// date, else the local completion day, else the local creation day (drafts).
+const sessionSortDate = `COALESCE(session_date, (completed_at AT TIME ZONE $1)::date, (created_at AT TIME ZONE $1)::date)`
+
+// query builds the list query; $1 is always the time zone name.
+func (f sessionFilter) query(tz string) (string, []any) {
+	args := []any{tz}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	where := []string{"deleted_at IS NULL"}
+	switch f.Status {
+	case "completed":
+		where = append(where, "completed_at IS NOT NULL")
+	case "draft":
+		where = append(where, "completed_at IS NULL")
+	}
+	if f.Day != 0 {
+		where = append(where, "day_num = "+arg(f.Day))
+	}
+	if f.From != "" {
+		where = append(where, sessionSortDate+" >= "+arg(f.From)+"::date")
+	}
+	if f.To != "" {
+		where = append(where, sessionSortDate+" <= "+arg(f.To)+"::date")
+	}
+	if f.Exercise != "" {
+		// by exercise, so sets logged under an alias or older name match too
+		ex := arg(f.Exercise)
+		where = append(where, "EXISTS (SELECT 1 FROM workout_items wi WHERE wi.workout_id = w.id AND (wi.label = "+ex+
+			" OR wi.exercise_id IN (SELECT e.id FROM exercises e WHERE e.name = "+ex+" OR "+ex+" = ANY(e.aliases))))")
+	}
+	if f.Q != "" {
+		pat := arg("%" + likeEscape(f.Q) + "%")
+		where = append(where, "(w.notes ILIKE "+pat+" OR EXISTS (SELECT 1 FROM workout_items wi WHERE wi.workout_id = w.id AND wi.label ILIKE "+pat+"))")
+	}
+	if f.afterDate != "" {
+		where = append(where, "("+sessionSortDate+", id) < ("+arg(f.afterDate)+"::date, "+arg(f.afterID)+")")
+	}
+
+	q := `SELECT id, day_num, session_date, completed_at, started_at, ended_at, ` + sessionSortDate + ` AS sort_date
+FROM workouts w
+WHERE ` + strings.Join(where, "\n  AND ")
+	q += "\nORDER BY sort_date DESC, id DESC\nLIMIT " + arg(sessionsPageSize+1)
+	return q, args
+}
+
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// heatmapWeeks is the number of full weeks shown before the current one.
+const heatmapWeeks = 52
+
+type HeatCell struct {
+	Date   time.Time
+	Count  int
+	Future bool
+}
+
+// Level buckets the count into the heatmap's colour steps (0-2).
+func (c HeatCell) Level() int {
+	if c.Count > 2 {
+		return 2
+	}
+	return c.Count
+}
+
+// buildHeatmap lays out heatmapWeeks+1 columns of 7 days, column-major, with
+// the last column holding the current week.
+func buildHeatmap(today time.Time, cs calSettings, byDay map[string][]CellSession) []HeatCell {
+	weekStart := today.AddDate(0, 0, -cs.weekOffset(today))
+	gridStart := weekStart.AddDate(0, 0, -7*heatmapWeeks)
+	cells := make([]HeatCell, 7*(heatmapWeeks+1))
+	for i := range cells {
+		d := gridStart.AddDate(0, 0, i)
+		cells[i] = HeatCell{
+			Date:   d,
+			Count:  len(byDay[d.Format("2006-01-02")]),
+			Future: d.After(today),
+		}
+	}
+	return cells
+}
+
+// streaks returns the run of consecutive training days ending today (or
+// yesterday, so an unlogged today doesn't reset it) and the longest run ever.
+func streaks(today time.Time, byDay map[string][]CellSession) (current, longest int) {
+	days := make([]time.Time, 0, len(byDay))
+	for k := range byDay {
+		if d, err := time.ParseInLocation("2006-01-02", k, today.Location()); err == nil {
+			days = append(days, d)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	run := 0
+	for i, d := range days {
+		if i > 0 && days[i-1].AddDate(0, 0, 1).Equal(d) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+
+	d := today
+	if len(byDay[d.Format("2006-01-02")]) == 0 {
+		d = d.AddDate(0, 0, -1)
+	}
+	for len(byDay[d.Format("2006-01-02")]) > 0 {
+		current++
+		d = d.AddDate(0, 0, -1)
+	}
+	return current, longest
+}
+
+type periodStats struct {
+	Sessions    int
+	Weeks       float64
+	PerWeek     float64
+	DaysTrained int
+	DaysPlanned int     // training days from the first session on
+	DaysHit     int     // planned days with a session
+	Adherence   float64 // percent of planned days with a session
+
+	Timed     int           // sessions with a recorded start and end
+	TotalTime time.Duration // summed over the timed sessions
+}
+
+// AvgTime is the mean length of the timed sessions, "" if there are none.
+func (ps periodStats) AvgTime() string {
+	if ps.Timed == 0 {
+		return ""
+	}
+	return fmtDuration(ps.TotalTime / time.Duration(ps.Timed))
+}
+
+// TotalTimeText renders TotalTime, "" if no session was timed.
+func (ps periodStats) TotalTimeText() string {
+	if ps.Timed == 0 {
+		return ""
+	}
+	return fmtDuration(ps.TotalTime)
+}
+
+// rotationStats summarises [start, today]. Every training day of sched from
+// the first logged session onwards is planned.
+func rotationStats(start, today time.Time, sched schedule, byDay map[string][]CellSession) periodStats {
+	var first time.Time
+	var ps periodStats
+	for d := start; !d.After(today); d = d.AddDate(0, 0, 1) {
+		sessions := byDay[d.Format("2006-01-02")]
+		n := len(sessions)
+		if n == 0 {
+			continue
+		}
+		for _, s := range sessions {
+			if s.Duration > 0 {
+				ps.Timed++
+				ps.TotalTime += s.Duration
+			}
+		}
+		if first.IsZero() {
+			first = d
+		}
+		ps.Sessions += n
+		ps.DaysTrained++
+	}
+	if first.IsZero() {
+		return ps
+	}
+	days := 0
+	for d := first; !d.After(today); d = d.AddDate(0, 0, 1) {
+		days++
+		if !sched.On(d) {
+			continue
+		}
+		ps.DaysPlanned++
+		if len(byDay[d.Format("2006-01-02")]) > 0 {
+			ps.DaysHit++
+		}
+	}
+	ps.Weeks = float64(days) / 7
+	if ps.Weeks < 1 {
+		ps.Weeks = 1
+	}
+	ps.PerWeek = float64(ps.Sessions) / ps.Weeks
+	if ps.DaysPlanned > 0 {
+		ps.Adherence = 100 * float64(ps.DaysHit) / float64(ps.DaysPlanned)
+	}
+	return ps
+}
+
+func handleStats(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loc := loadLoc()
+		cs := loadCalSettings(w, r)
+		now := time.Now().In(loc)
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+		beginning := time.Date(1970, 1, 1, 0, 0, 0, 0, loc)
+		byDay, err := completedByDay(r.Context(), pool, loc, beginning, today.AddDate(0, 0, 1))
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+
+		sched := loadSchedule()
+		cur, longest := streaks(today, byDay)
+		heat := buildHeatmap(today, cs, byDay)
+		data := struct {
+			Heatmap []HeatCell
+			Current int
+			Longest int
+			Year    periodStats
+			Month   periodStats
+		}{
+			Heatmap: heat,
+			Current: cur,
+			Longest: longest,
+			Year:    rotationStats(heat[0].Date, today, sched, byDay),
+			Month:   rotationStats(today.AddDate(0, 0, -27), today, sched, byDay),
+		}
+
+		t := mustTpl("web/templates/base.gohtml", "web/templates/stats.gohtml")
+		if err := t.ExecuteTemplate(w, "base.gohtml", data); err != nil {
+			http.Error(w, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
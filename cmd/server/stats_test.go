@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// trained marks one session on each of the given days.
+func trained(days ...string) map[string][]CellSession {
+	out := map[string][]CellSession{}
+	for _, d := range days {
+		out[d] = append(out[d], CellSession{})
+	}
+	return out
+}
+
+func TestStreaks(t *testing.T) {
+	today := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		byDay            map[string][]CellSession
+		current, longest int
+	}{
+		{"nothing logged", trained(), 0, 0},
+		{"today only", trained("2026-10-18"), 1, 1},
+		{"ends yesterday", trained("2026-10-16", "2026-10-17"), 2, 2},
+		{"broken two days ago", trained("2026-10-14", "2026-10-15", "2026-10-16"), 0, 3},
+		{"longer run earlier", trained("2026-09-01", "2026-09-02", "2026-09-03", "2026-09-04", "2026-10-17", "2026-10-18"), 2, 4},
+		{"across a month end", trained("2026-09-30", "2026-10-01"), 0, 2},
+		{"two sessions a day count once", map[string][]CellSession{"2026-10-18": {{}, {}}}, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur, longest := streaks(today, tt.byDay)
+			if cur != tt.current || longest != tt.longest {
+				t.Errorf("streaks = %d, %d; want %d, %d", cur, longest, tt.current, tt.longest)
+			}
+		})
+	}
+}
+
+func TestRotationStats(t *testing.T) {
+	today := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC) // a Sunday
+	start := today.AddDate(0, 0, -27)
+	everyDay := schedule{true, true, true, true, true, true, true}
+	monWedFri := schedule{time.Monday: true, time.Wednesday: true, time.Friday: true}
+
+	tests := []struct {
+		name      string
+		sched     schedule
+		byDay     map[string][]CellSession
+		want      periodStats
+		adherence float64
+	}{
+		{"nothing logged", everyDay, trained(), periodStats{}, 0},
+		{
+			// planning starts at the first session: Oct 12-18 is 7 days, 4 hit
+			"every day from the first session", everyDay,
+			trained("2026-10-12", "2026-10-13", "2026-10-15", "2026-10-18"),
+			periodStats{Sessions: 4, Weeks: 1, PerWeek: 4, DaysTrained: 4, DaysPlanned: 7, DaysHit: 4},
+			100 * 4.0 / 7,
+		},
+		{
+			// Mon Oct 5 to Sun Oct 18: six planned days; the Saturday session is extra
+			"training days only", monWedFri,
+			map[string][]CellSession{"2026-10-05": {{}, {}}, "2026-10-07": {{}}, "2026-10-12": {{}}, "2026-10-17": {{}}},
+			periodStats{Sessions: 5, Weeks: 2, PerWeek: 2.5, DaysTrained: 4, DaysPlanned: 6, DaysHit: 3},
+			50,
+		},
+		{
+			"a first session today still spans a week", everyDay,
+			trained("2026-10-18"),
+			periodStats{Sessions: 1, Weeks: 1, PerWeek: 1, DaysTrained: 1, DaysPlanned: 1, DaysHit: 1},
+			100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rotationStats(start, today, tt.sched, tt.byDay)
+			adherence := got.Adherence
+			got.Adherence = 0
+			if got != tt.want {
+				t.Errorf("rotationStats = %+v, want %+v", got, tt.want)
+			}
+			if d := adherence - tt.adherence; d > 1e-9 || d < -1e-9 {
+				t.Errorf("adherence %.3f, want %.3f", adherence, tt.adherence)
+			}
+		})
+	}
+}
+
+func TestRotationStatsTimes(t *testing.T) {
+	today := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	byDay := map[string][]CellSession{
+		"2026-10-17": {{Duration: 50 * time.Minute}, {}},
+		"2026-10-18": {{Duration: 70 * time.Minute}},
+	}
+	ps := rotationStats(today.AddDate(0, 0, -6), today, schedule{true, true, true, true, true, true, true}, byDay)
+	if ps.Timed != 2 || ps.TotalTime != 2*time.Hour {
+		t.Errorf("timed %d for %v, want 2 for 2h", ps.Timed, ps.TotalTime)
+	}
+	if got, want := ps.AvgTime(), fmtDuration(time.Hour); got != want {
+		t.Errorf("AvgTime = %q, want %q", got, want)
+	}
+	if got := (periodStats{}).AvgTime(); got != "" {
+		t.Errorf("untimed AvgTime = %q, want empty", got)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/db"
+)
+
+// syncResult is the JSON body of every /sync/workouts/{uuid} response.
+type syncResult struct {
+	WorkoutID int64             `json:"workout_id,omitempty"`
+	Revision  int               `json:"revision"`
+	Duplicate bool              `json:"duplicate,omitempty"` // payload was already applied
+	Conflict  string            `json:"conflict,omitempty"`  // "changed" or "deleted"
+	Server    json.RawMessage   `json:"server,omitempty"`    // server copy on conflict
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+func writeSyncResult(w http.ResponseWriter, status int, res syncResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// syncDecision says what to do with a payload for a workout that already
+// exists: a replay of the payload last applied is a duplicate; otherwise a
+// deleted workout, or one changed since base without force, is a conflict
+// ("deleted" or "changed").
+func syncDecision(st db.SyncState, hash string, base int, force bool) (duplicate bool, conflict string) {
+	switch {
+	case st.SyncHash == hash:
+		return true, ""
+	case st.DeletedAt != nil:
+		return false, "deleted"
+	case st.Revision != base && !force:
+		return false, "changed"
+	}
+	return false, ""
+}
+
+// handleSync applies a session form queued while offline. The body is the
+// same urlencoded form /session/save takes, plus:
+//
+//	base_revision  the workout revision the client last saw (0 if none)
+//	complete=1     also mark the workout complete
+//	force=1        overwrite even if the server changed since base_revision
+//
+// Replaying an already-applied payload is a no-op, so clients can retry
+// freely. A workout changed or deleted on the server since base_revision is
+// reported as 409 with the server's copy instead of being overwritten.
+func handleSync(pool *pgxpool.Pool, hooks *webhooks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := strings.ToLower(r.PathValue("uuid"))
+		if !validUUID(uuid) {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		base, _ := strconv.Atoi(r.PostForm.Get("base_revision"))
+		force := r.PostForm.Get("force") == "1"
+		complete := r.PostForm.Get("complete") == "1"
+
+		// the payload hash covers the session data only, not how it was sent
+		r.PostForm.Del("base_revision")
+		r.PostForm.Del("force")
+		r.PostForm.Del("workout_id")
+		r.PostForm.Set("client_uuid", uuid)
+		if complete {
+			r.PostForm.Set("complete", "1")
+		}
+		sum := sha256.Sum256([]byte(r.PostForm.Encode()))
+		hash := hex.EncodeToString(sum[:])
+
+		f, errs := parseSessionForm(r)
+		if errs != nil {
+			writeSyncResult(w, http.StatusUnprocessableEntity, syncResult{Errors: errs})
+			return
+		}
+
+		ctx := r.Context()
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		st, exists, err := db.WorkoutByClientUUID(ctx, tx, uuid)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		var before json.RawMessage
+		if exists {
+			duplicate, conflict := syncDecision(st, hash, base, force)
+			if duplicate {
+				writeSyncResult(w, http.StatusOK, syncResult{WorkoutID: st.ID, Revision: st.Revision, Duplicate: true})
+				return
+			}
+			if before, err = db.Snapshot(ctx, tx, st.ID); err != nil {
+				http.Error(w, "db error", http.StatusInternalServerError)
+				return
+			}
+			if conflict != "" {
+				writeSyncResult(w, http.StatusConflict, syncResult{
+					WorkoutID: st.ID, Revision: st.Revision, Conflict: conflict, Server: before,
+				})
+				return
+			}
+			f.WorkoutID = st.ID
+		}
+
+		if _, err := persistSession(ctx, tx, f); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec(ctx, `UPDATE workouts SET sync_hash=$2 WHERE id=$1`, f.WorkoutID, hash); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if complete {
+			// keep the original completion time when replaying onto a completed workout
+			if _, err := tx.Exec(ctx, `UPDATE workouts SET completed_at=COALESCE(completed_at, now()), ended_at=COALESCE(ended_at, now()) WHERE id=$1`, f.WorkoutID); err != nil {
+				http.Error(w, "db error", http.StatusInternalServerError)
+				return
+			}
+			after, err := db.Snapshot(ctx, tx, f.WorkoutID)
+			if err != nil {
+				http.Error(w, "db error", http.StatusInternalServerError)
+				return
+			}
+			err = db.RecordAudit(ctx, tx, db.AuditEvent{
+				WorkoutID: f.WorkoutID, Action: db.AuditComplete, Actor: actorOf(r), Before: before, After: after,
+			})
+			if err != nil {
+				http.Error(w, "db error", http.StatusInternalServerError)
+				return
+			}
+		} else if err := auditSave(ctx, tx, r, f.WorkoutID, before); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if complete && (!exists || st.CompletedAt == nil) {
+			hooks.workoutCompleted(ctx, pool, f.WorkoutID)
+		}
+		writeSyncResult(w, http.StatusOK, syncResult{WorkoutID: f.WorkoutID, Revision: f.Revision})
+	}
+}
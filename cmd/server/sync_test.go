@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"traininglog/internal/db"
+)
+
+func TestSyncDecision(t *testing.T) {
+	deleted := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		st        db.SyncState
+		hash      string
+		base      int
+		force     bool
+		duplicate bool
+		conflict  string
+	}{
+		{"replay", db.SyncState{Revision: 4, SyncHash: "h1"}, "h1", 2, false, true, ""},
+		{"replay onto deleted", db.SyncState{Revision: 4, SyncHash: "h1", DeletedAt: &deleted}, "h1", 4, false, true, ""},
+		{"next change", db.SyncState{Revision: 4, SyncHash: "h1"}, "h2", 4, false, false, ""},
+		{"changed on server", db.SyncState{Revision: 5, SyncHash: "h1"}, "h2", 4, false, false, "changed"},
+		{"changed, forced", db.SyncState{Revision: 5, SyncHash: "h1"}, "h2", 4, true, false, ""},
+		{"deleted", db.SyncState{Revision: 4, SyncHash: "h1", DeletedAt: &deleted}, "h2", 4, false, false, "deleted"},
+		{"deleted, forced", db.SyncState{Revision: 4, SyncHash: "h1", DeletedAt: &deleted}, "h2", 4, true, false, "deleted"},
+		{"never synced", db.SyncState{Revision: 1}, "h1", 1, false, false, ""},
+	}
+	for _, tt := range tests {
+		dup, conflict := syncDecision(tt.st, tt.hash, tt.base, tt.force)
+		if dup != tt.duplicate || conflict != tt.conflict {
+			t.Errorf("%s: got (%v, %q), want (%v, %q)", tt.name, dup, conflict, tt.duplicate, tt.conflict)
+		}
+	}
+}
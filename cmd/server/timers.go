@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"traininglog/internal/plan"
+)
+
+// Bounds for timer lengths, both for configuration and for rests reported
+// by the page.
+const (
+	maxRestSecs     = 15 * 60
+	maxIntervalSecs = 10 * 60
+	maxRecordedRest = 60 * 60
+)
+
+// timerSettings holds the server-wide timer defaults; plan items that set
+// their own RestSecs or IntervalSecs keep them.
+type timerSettings struct {
+	RestSecs     int
+	IntervalSecs int
+}
+
+// loadTimerSettings reads REST_SECONDS and CIRCUIT_INTERVAL_SECONDS,
+// falling back to the plan defaults when unset or out of range.
+func loadTimerSettings() timerSettings {
+	ts := timerSettings{RestSecs: plan.DefaultRestSecs, IntervalSecs: plan.DefaultIntervalSecs}
+	if n, err := strconv.Atoi(os.Getenv("REST_SECONDS")); err == nil && n > 0 && n <= maxRestSecs {
+		ts.RestSecs = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("CIRCUIT_INTERVAL_SECONDS")); err == nil && n > 0 && n <= maxIntervalSecs {
+		ts.IntervalSecs = n
+	}
+	return ts
+}
+
+// Rest is the rest between sets of it.
+func (ts timerSettings) Rest(it plan.Item) int {
+	if it.RestSecs > 0 {
+		return it.RestSecs
+	}
+	return ts.RestSecs
+}
+
+// Interval is the EMOM slot length for circuit heading it.
+func (ts timerSettings) Interval(it plan.Item) int {
+	if it.IntervalSecs > 0 {
+		return it.IntervalSecs
+	}
+	return ts.IntervalSecs
+}
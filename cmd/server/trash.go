@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/db"
+)
+
+// undoToast is the "Session #N deleted · Undo" notice shown after a delete.
+// Redirect sends the undo to the restored session's page instead of
+// refreshing the current one.
+type undoToast struct {
+	ID       int64
+	Redirect bool
+}
+
+type trashRow struct {
+	ID        int64
+	DayNum    int
+	Date      string
+	Completed bool
+	DeletedAt string
+}
+
+// handleTrash lists soft-deleted workouts, most recently deleted first.
+func handleTrash(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loc := loadLoc()
+		rows, err := pool.Query(r.Context(), `
+	SELECT id, day_num, session_date, completed_at, deleted_at
+	FROM workouts
+	WHERE deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC, id DESC
+	`)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var out []trashRow
+		for rows.Next() {
+			var tr trashRow
+			var sd, ct *time.Time
+			var dt time.Time
+			if err := rows.Scan(&tr.ID, &tr.DayNum, &sd, &ct, &dt); err != nil {
+				http.Error(w, "db scan error", http.StatusInternalServerError)
+				return
+			}
+			tr.Date = workoutDate(sd, ct, loc)
+			tr.Completed = ct != nil
+			tr.DeletedAt = dt.In(loc).Format("2006-01-02 15:04")
+			out = append(out, tr)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "db rows error", http.StatusInternalServerError)
+			return
+		}
+
+		t := mustTpl("web/templates/base.gohtml", "web/templates/trash.gohtml")
+		if err := t.ExecuteTemplate(w, "base.gohtml", struct{ Rows []trashRow }{Rows: out}); err != nil {
+			http.Error(w, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// handleTrashEmpty permanently deletes every trashed workout (items cascade).
+func handleTrashEmpty(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+		if err := db.AuditPurgeTrash(ctx, tx, actorOf(r)); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM workouts WHERE deleted_at IS NOT NULL`); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("HX-Refresh", "true")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// handleTrashPurge permanently deletes one trashed workout (items cascade).
+func handleTrashPurge(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(r)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		ok, err := auditedExec(r.Context(), pool, r, id, db.AuditPurge,
+			`DELETE FROM workouts WHERE id=$1 AND deleted_at IS NOT NULL`)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
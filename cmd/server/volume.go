@@ -0,0 +1,205 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/db"
+	"traininglog/internal/plan"
+)
+
+// volumeWeeks is how many weeks /stats/volume covers, the current one last.
+const volumeWeeks = 12
+
+// secondaryShare is how much a set counts towards the secondary muscle
+// groups of its exercise; the primary groups get the whole set.
+const secondaryShare = 0.5
+
+// trendWeeks is the window compared by muscleVolume.Trend.
+const trendWeeks = 4
+
+// volumeCell is one muscle group's week: hard sets (any set with a value
+// above zero) and reps. Timed sets count as sets but add no reps.
+type volumeCell struct {
+	Sets float64
+	Reps int
+}
+
+// Level buckets the sets into the table's shading steps (0-3).
+func (c volumeCell) Level() int {
+	switch {
+	case c.Sets == 0:
+		return 0
+	case c.Sets < 6:
+		return 1
+	case c.Sets < 12:
+		return 2
+	}
+	return 3
+}
+
+// muscleVolume is one muscle group's weeks, oldest first.
+type muscleVolume struct {
+	Muscle string
+	Weeks  []volumeCell
+}
+
+// Recent is the mean weekly sets over the last trendWeeks weeks; Before is
+// the mean over the trendWeeks weeks before those.
+func (m muscleVolume) Recent() float64 { return meanSets(m.Weeks[len(m.Weeks)-trendWeeks:]) }
+func (m muscleVolume) Before() float64 {
+	return meanSets(m.Weeks[len(m.Weeks)-2*trendWeeks : len(m.Weeks)-trendWeeks])
+}
+
+// RecentReps is the mean weekly reps over the last trendWeeks weeks.
+func (m muscleVolume) RecentReps() float64 {
+	var sum int
+	for _, c := range m.Weeks[len(m.Weeks)-trendWeeks:] {
+		sum += c.Reps
+	}
+	return float64(sum) / trendWeeks
+}
+
+// Trend compares Recent with Before: "up" or "down" past a 10% change,
+// "new" when there was nothing before, else "flat".
+func (m muscleVolume) Trend() string {
+	now, before := m.Recent(), m.Before()
+	switch {
+	case before == 0 && now > 0:
+		return "new"
+	case now > before*1.1:
+		return "up"
+	case now < before*0.9:
+		return "down"
+	}
+	return "flat"
+}
+
+// balanceWeek is a week's hard sets on push and on pull muscles.
+type balanceWeek struct {
+	Start      time.Time
+	Push, Pull int
+}
+
+// Ratio is push sets per pull set, 0 when nothing was pulled.
+func (b balanceWeek) Ratio() float64 {
+	if b.Pull == 0 {
+		return 0
+	}
+	return float64(b.Push) / float64(b.Pull)
+}
+
+func meanSets(cs []volumeCell) float64 {
+	var sum float64
+	for _, c := range cs {
+		sum += c.Sets
+	}
+	return sum / float64(len(cs))
+}
+
+// buildVolume buckets sets into the volumeWeeks weeks starting at first, a
+// local week start.
+func buildVolume(sets []db.LoggedSet, first time.Time, loc *time.Location) ([]muscleVolume, []balanceWeek) {
+	byMuscle := map[string]*muscleVolume{}
+	muscle := func(name string) *muscleVolume {
+		if m, ok := byMuscle[name]; ok {
+			return m
+		}
+		m := &muscleVolume{Muscle: name, Weeks: make([]volumeCell, volumeWeeks)}
+		byMuscle[name] = m
+		return m
+	}
+	balance := make([]balanceWeek, volumeWeeks)
+	for i := range balance {
+		balance[i].Start = first.AddDate(0, 0, 7*i)
+	}
+
+	for _, s := range sets {
+		if s.Value <= 0 {
+			continue
+		}
+		d, err := time.ParseInLocation("2006-01-02", s.Day(loc), loc)
+		if err != nil || d.Before(first) {
+			continue
+		}
+		w := int(math.Round(d.Sub(first).Hours()/24)) / 7
+		if w >= volumeWeeks {
+			continue
+		}
+		for _, g := range s.Primary {
+			m := muscle(g)
+			m.Weeks[w].Sets++
+			if s.Unit == "reps" {
+				m.Weeks[w].Reps += s.Value
+			}
+		}
+		for _, g := range s.Secondary {
+			muscle(g).Weeks[w].Sets += secondaryShare
+		}
+		if slices.ContainsFunc(s.Primary, func(g string) bool { return slices.Contains(plan.PushMuscles, g) }) {
+			balance[w].Push++
+		}
+		if slices.ContainsFunc(s.Primary, func(g string) bool { return slices.Contains(plan.PullMuscles, g) }) {
+			balance[w].Pull++
+		}
+	}
+
+	out := make([]muscleVolume, 0, len(byMuscle))
+	for _, m := range byMuscle {
+		out = append(out, *m)
+	}
+	// busiest muscle groups first, by recent volume
+	sort.Slice(out, func(i, j int) bool {
+		if a, b := out[i].Recent(), out[j].Recent(); a != b {
+			return a > b
+		}
+		return out[i].Muscle < out[j].Muscle
+	})
+	return out, balance
+}
+
+func handleVolume(pool *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loc := loadLoc()
+		cs := loadCalSettings(w, r)
+		now := time.Now().In(loc)
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		first := today.AddDate(0, 0, -cs.weekOffset(today)-7*(volumeWeeks-1))
+
+		sets, err := db.LoggedSets(r.Context(), pool, first, today.AddDate(0, 0, 1))
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		muscles, balance := buildVolume(sets, first, loc)
+
+		// push/pull over the trend window
+		var recent balanceWeek
+		for _, b := range balance[volumeWeeks-trendWeeks:] {
+			recent.Push += b.Push
+			recent.Pull += b.Pull
+		}
+		data := struct {
+			Muscles    []muscleVolume
+			Balance    []balanceWeek
+			Recent     balanceWeek
+			TrendWeeks int
+			Push, Pull string // the muscle groups on each side
+		}{
+			Muscles: muscles, Balance: balance, Recent: recent, TrendWeeks: trendWeeks,
+			Push: strings.Join(plan.PushMuscles, ", "), Pull: strings.Join(plan.PullMuscles, ", "),
+		}
+
+		t := mustTpl("web/templates/base.gohtml", "web/templates/volume.gohtml")
+		if err := t.ExecuteTemplate(w, "base.gohtml", data); err != nil {
+			http.Error(w, "template error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"traininglog/internal/db"
+)
+
+func TestBuildVolume(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	// 12 weeks from Monday 2026-08-03 to the week of 2026-10-19, across the
+	// October DST change
+	first := time.Date(2026, 8, 3, 0, 0, 0, 0, loc)
+	at := func(s string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	date := func(s string) *time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return &d
+	}
+	pushups := func(done time.Time, session *time.Time, reps int) db.LoggedSet {
+		return db.LoggedSet{CompletedAt: done, SessionDate: session, Exercise: "pushups", Unit: "reps",
+			Primary: []string{"chest", "triceps"}, Secondary: []string{"shoulders"}, Value: reps}
+	}
+	rows := db.LoggedSet{CompletedAt: at("2026-08-04 18:00"), Exercise: "rows", Unit: "reps",
+		Primary: []string{"back"}, Secondary: []string{"biceps"}, Value: 10}
+	plank := db.LoggedSet{CompletedAt: at("2026-08-04 18:00"), Exercise: "plank", Unit: "secs",
+		Primary: []string{"core"}, Value: 60}
+
+	sets := []db.LoggedSet{
+		pushups(at("2026-08-02 23:30"), nil, 50),                // before first: dropped
+		pushups(at("2026-08-03 00:30"), nil, 10),                // week 0, though still 2 Aug in UTC
+		pushups(at("2026-08-11 09:00"), date("2026-08-09"), 12), // session date wins: week 0
+		pushups(at("2026-08-04 18:00"), nil, 0),                 // no value: not a hard set
+		rows, plank,                                             // week 0
+		pushups(at("2026-10-26 07:00"), nil, 8),                // week 12, after the DST change: dropped
+		pushups(at("2026-10-25 23:00"), nil, 9),                // last day of week 11
+		pushups(at("2026-10-19 00:10"), nil, 11),               // first minutes of week 11
+		pushups(at("2026-10-18 23:50"), date("2026-10-19"), 7), // session date: week 11
+	}
+	muscles, balance := buildVolume(sets, first, loc)
+
+	byName := map[string]muscleVolume{}
+	for _, m := range muscles {
+		byName[m.Muscle] = m
+	}
+	wantWeeks := map[string]map[int]volumeCell{
+		"chest":     {0: {Sets: 2, Reps: 22}, 11: {Sets: 3, Reps: 27}},
+		"triceps":   {0: {Sets: 2, Reps: 22}, 11: {Sets: 3, Reps: 27}},
+		"shoulders": {0: {Sets: 1}, 11: {Sets: 1.5}},
+		"back":      {0: {Sets: 1, Reps: 10}},
+		"biceps":    {0: {Sets: 0.5}},
+		"core":      {0: {Sets: 1}},
+	}
+	if len(byName) != len(wantWeeks) {
+		t.Errorf("muscles = %v, want %d groups", muscles, len(wantWeeks))
+	}
+	for name, want := range wantWeeks {
+		m, ok := byName[name]
+		if !ok {
+			t.Errorf("no %s", name)
+			continue
+		}
+		for w, c := range m.Weeks {
+			if c != want[w] {
+				t.Errorf("%s week %d = %+v, want %+v", name, w, c, want[w])
+			}
+		}
+	}
+	// chest and triceps tie on recent volume and sort by name
+	if muscles[0].Muscle != "chest" || muscles[1].Muscle != "triceps" {
+		t.Errorf("order = %s, %s, want chest, triceps first", muscles[0].Muscle, muscles[1].Muscle)
+	}
+
+	if len(balance) != volumeWeeks {
+		t.Fatalf("%d balance weeks, want %d", len(balance), volumeWeeks)
+	}
+	if b := balance[0]; b.Push != 2 || b.Pull != 1 || b.Ratio() != 2 {
+		t.Errorf("week 0 balance = %+v ratio %v, want 2 push, 1 pull", b, b.Ratio())
+	}
+	if b := balance[11]; b.Push != 3 || b.Pull != 0 || b.Ratio() != 0 {
+		t.Errorf("week 11 balance = %+v ratio %v, want 3 push, 0 pull", b, b.Ratio())
+	}
+	if got := balance[11].Start; !got.Equal(time.Date(2026, 10, 19, 0, 0, 0, 0, loc)) {
+		t.Errorf("week 11 starts %v", got)
+	}
+}
+
+func TestMuscleVolumeTrend(t *testing.T) {
+	weeks := func(before, recent float64) muscleVolume {
+		m := muscleVolume{Weeks: make([]volumeCell, volumeWeeks)}
+		for i := volumeWeeks - 2*trendWeeks; i < volumeWeeks; i++ {
+			m.Weeks[i].Sets = before
+			if i >= volumeWeeks-trendWeeks {
+				m.Weeks[i].Sets = recent
+			}
+		}
+		return m
+	}
+	tests := []struct {
+		before, recent float64
+		want           string
+	}{
+		{0, 0, "flat"},
+		{0, 3, "new"},
+		{10, 10.5, "flat"},
+		{10, 12, "up"},
+		{10, 8, "down"},
+		{10, 0, "down"},
+	}
+	for _, tt := range tests {
+		if got := weeks(tt.before, tt.recent).Trend(); got != tt.want {
+			t.Errorf("Trend(%v → %v) = %s, want %s", tt.before, tt.recent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"traininglog/internal/db"
+)
+
+// Webhook events.
+const (
+	hookCompleted = "workout.completed"
+	hookDeleted   = "workout.deleted"
+	hookPR        = "pr.set"
+)
+
+// Delivery retries: hookAttempts tries in all, waiting hookBackoff, then
+// twice as long after each failure.
+const (
+	hookAttempts = 5
+	hookBackoff  = 2 * time.Second
+)
+
+var hookClient = &http.Client{Timeout: 10 * time.Second}
+
+// hookSleep waits between delivery attempts; tests replace it.
+var hookSleep = time.Sleep
+
+// hookWait is the wait after failed attempt n (from 1).
+func hookWait(n int) time.Duration { return hookBackoff << (n - 1) }
+
+// webhooks posts workout events to the configured URLs. A nil *webhooks
+// sends nothing.
+type webhooks struct {
+	urls   []string
+	secret []byte
+	events map[string]bool
+}
+
+// hookPayload is the JSON body of every delivery.
+type hookPayload struct {
+	Event     string          `json:"event"`
+	At        time.Time       `json:"at"`
+	WorkoutID int64           `json:"workout_id"`
+	Workout   json.RawMessage `json:"workout"` // as in the audit log
+	PRs       []hookRecord    `json:"prs,omitempty"`
+}
+
+type hookRecord struct {
+	Exercise string `json:"exercise"`
+	Unit     string `json:"unit"`
+	Value    int    `json:"value"`
+	Previous int    `json:"previous"`
+}
+
+// loadWebhooks reads WEBHOOK_URLS (comma-separated), WEBHOOK_SECRET, the
+// HMAC-SHA256 key deliveries are signed with, and optionally WEBHOOK_EVENTS,
+// the events to send (default all of workout.completed, workout.deleted
+// and pr.set). Without URLs it returns nil.
+func loadWebhooks() (*webhooks, error) {
+	h := &webhooks{secret: []byte(os.Getenv("WEBHOOK_SECRET")), events: map[string]bool{}}
+	for _, u := range strings.Split(os.Getenv("WEBHOOK_URLS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			h.urls = append(h.urls, u)
+		}
+	}
+	if len(h.urls) == 0 {
+		return nil, nil
+	}
+	if len(h.secret) == 0 {
+		return nil, errors.New("WEBHOOK_SECRET not set")
+	}
+	for _, e := range strings.Split(os.Getenv("WEBHOOK_EVENTS"), ",") {
+		switch e = strings.TrimSpace(e); e {
+		case "":
+		case hookCompleted, hookDeleted, hookPR:
+			h.events[e] = true
+		default:
+			return nil, fmt.Errorf("unknown event %q in WEBHOOK_EVENTS (want %s, %s or %s)", e, hookCompleted, hookDeleted, hookPR)
+		}
+	}
+	if len(h.events) == 0 {
+		h.events = map[string]bool{hookCompleted: true, hookDeleted: true, hookPR: true}
+	}
+	return h, nil
+}
+
+// sign is the X-Traininglog-Signature of body: "sha256=" and the hex
+// HMAC-SHA256 of the raw body under the secret.
+func (h *webhooks) sign(body []byte) string {
+	m := hmac.New(sha256.New, h.secret)
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// send delivers p to every URL in the background.
+func (h *webhooks) send(p hookPayload) {
+	if h == nil || !h.events[p.Event] {
+		return
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("webhook %s: %v", p.Event, err)
+		return
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	for _, u := range h.urls {
+		go h.deliver(u, p.Event, hex.EncodeToString(id), body)
+	}
+}
+
+// deliver posts body to url, retrying with backoff on network errors, 429
+// and 5xx answers. Other answers are final.
+func (h *webhooks) deliver(url, event, id string, body []byte) {
+	for attempt := 1; ; attempt++ {
+		retry, err := h.post(url, event, id, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == hookAttempts {
+			log.Printf("webhook %s to %s: giving up after %d attempt(s): %v", event, url, attempt, err)
+			return
+		}
+		hookSleep(hookWait(attempt))
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (h *webhooks) post(url, event, id string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "traininglog-webhook")
+	req.Header.Set("X-Traininglog-Event", event)
+	req.Header.Set("X-Traininglog-Delivery", id) // the same on every retry
+	req.Header.Set("X-Traininglog-Signature", h.sign(body))
+	resp, err := hookClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode/100 == 2:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, errors.New(resp.Status)
+	default:
+		return false, errors.New(resp.Status)
+	}
+}
+
+// workoutCompleted sends workout.completed for workout id and, if it beat an
+// earlier best, pr.set. Call it once the completion is committed.
+func (h *webhooks) workoutCompleted(ctx context.Context, pool *pgxpool.Pool, id int64) {
+	if h == nil {
+		return
+	}
+	snap, err := db.Snapshot(ctx, pool, id)
+	if err != nil {
+		log.Printf("webhook %s: %v", hookCompleted, err)
+		return
+	}
+	p := hookPayload{Event: hookCompleted, At: time.Now().UTC(), WorkoutID: id, Workout: snap}
+	h.send(p)
+	if !h.events[hookPR] {
+		return
+	}
+	// the workout's day, as /reports dates it
+	loc := loadLoc()
+	var sd, ct *time.Time
+	if err := pool.QueryRow(ctx, `SELECT session_date, completed_at FROM workouts WHERE id=$1`, id).Scan(&sd, &ct); err != nil {
+		log.Printf("webhook %s: %v", hookPR, err)
+		return
+	}
+	day, err := time.ParseInLocation("2006-01-02", workoutDate(sd, ct, loc), loc)
+	if err != nil {
+		return
+	}
+	recs, err := db.WorkoutRecords(ctx, pool, id, day)
+	if err != nil {
+		log.Printf("webhook %s: %v", hookPR, err)
+		return
+	}
+	p.Event = hookPR
+	for _, r := range recs {
+		p.PRs = append(p.PRs, hookRecord{Exercise: r.Exercise, Unit: r.Unit, Value: r.Value, Previous: r.Previous})
+	}
+	if len(p.PRs) > 0 {
+		h.send(p)
+	}
+}
+
+// workoutDeleted sends workout.deleted for workout id, moved to the trash.
+func (h *webhooks) workoutDeleted(ctx context.Context, pool *pgxpool.Pool, id int64) {
+	if h == nil {
+		return
+	}
+	snap, err := db.Snapshot(ctx, pool, id)
+	if err != nil {
+		log.Printf("webhook %s: %v", hookDeleted, err)
+		return
+	}
+	h.send(hookPayload{Event: hookDeleted, At: time.Now().UTC(), WorkoutID: id, Workout: snap})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWebhookSign(t *testing.T) {
+	tests := []struct {
+		secret, body, want string
+	}{
+		// RFC 4231 test case 2
+		{"Jefe", "what do ya want for nothing?", "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{"s3cret", `{"event":"pr.set"}`, "sha256=59af2d520d238a347776b19bdcb0378177b51b926151be616b175c0d33697608"},
+		{"s3cret", "", "sha256=91dfac70c5348b04e1babb8b421ac92cec08b565b49ca16130dccb72503647b7"},
+	}
+	for _, tt := range tests {
+		h := &webhooks{secret: []byte(tt.secret)}
+		if got := h.sign([]byte(tt.body)); got != tt.want {
+			t.Errorf("sign(%q) under %q = %s, want %s", tt.body, tt.secret, got, tt.want)
+		}
+	}
+}
+
+func TestHookWait(t *testing.T) {
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second}
+	for i, w := range want {
+		if got := hookWait(i + 1); got != w {
+			t.Errorf("hookWait(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestWebhookDeliver(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers []int // status per attempt, the last repeated
+		tries   int
+	}{
+		{"delivered", []int{200}, 1},
+		{"accepted", []int{204}, 1},
+		{"retried until delivered", []int{503, 429, 200}, 3},
+		{"gives up on 5xx", []int{500}, hookAttempts},
+		{"4xx is final", []int{400}, 1},
+		{"404 after a 502", []int{502, 404}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var waits []time.Duration
+			sleep := hookSleep
+			hookSleep = func(d time.Duration) { waits = append(waits, d) }
+			defer func() { hookSleep = sleep }()
+
+			var ids []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ids = append(ids, r.Header.Get("X-Traininglog-Delivery"))
+				if r.Header.Get("X-Traininglog-Event") != hookPR || r.Header.Get("X-Traininglog-Signature") != "sha256=59af2d520d238a347776b19bdcb0378177b51b926151be616b175c0d33697608" {
+					t.Errorf("headers %v", r.Header)
+				}
+				w.WriteHeader(tt.answers[min(len(ids), len(tt.answers))-1])
+			}))
+			defer srv.Close()
+
+			h := &webhooks{secret: []byte("s3cret")}
+			h.deliver(srv.URL, hookPR, "d1", []byte(`{"event":"pr.set"}`))
+			if len(ids) != tt.tries {
+				t.Fatalf("%d attempts, want %d", len(ids), tt.tries)
+			}
+			if slices.ContainsFunc(ids, func(id string) bool { return id != "d1" }) {
+				t.Errorf("delivery ids %v, want d1 throughout", ids)
+			}
+			var want []time.Duration
+			for n := 1; n < tt.tries; n++ {
+				want = append(want, hookWait(n))
+			}
+			if !slices.Equal(waits, want) {
+				t.Errorf("waited %v, want %v", waits, want)
+			}
+		})
+	}
+}
+
+func TestWebhookDeliverUnreachable(t *testing.T) {
+	sleep := hookSleep
+	calls := 0
+	hookSleep = func(time.Duration) { calls++ }
+	defer func() { hookSleep = sleep }()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close() // connection refused from here on
+	(&webhooks{secret: []byte("k")}).deliver(url, hookCompleted, "d", nil)
+	if calls != hookAttempts-1 {
+		t.Errorf("%d waits, want %d: network errors are retried", calls, hookAttempts-1)
+	}
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Querier is satisfied by both *pgxpool.Pool and pgx.Tx, so helpers can run
+// inside or outside a handler's transaction.
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Audit actions.
+const (
+	AuditCreate   = "create"
+	AuditSave     = "save"
+	AuditEdit     = "edit" // save of an already completed workout
+	AuditComplete = "complete"
+	AuditDelete   = "delete"
+	AuditRestore  = "restore"
+	AuditPurge    = "purge"
+)
+
+// snapshotExpr renders workout w and its items as one JSONB document. Row
+// ids, item timestamps and sync bookkeeping are dropped so re-inserting
+// unchanged items produces an identical snapshot.
+const snapshotExpr = `(to_jsonb(w) - 'id' - 'revision' - 'sync_hash') || jsonb_build_object('items', COALESCE((
+	SELECT jsonb_agg(to_jsonb(wi) - 'id' - 'workout_id' - 'created_at' ORDER BY wi.label, wi.set_index)
+	FROM workout_items wi WHERE wi.workout_id = w.id), '[]'::jsonb))`
+
+type AuditEvent struct {
+	ID        int64
+	WorkoutID int64
+	Action    string
+	Actor     string
+	At        time.Time
+	Before    json.RawMessage // nil for create
+	After     json.RawMessage // nil for purge
+}
+
+// Snapshot returns the current state of a workout, or nil if it doesn't exist.
+func Snapshot(ctx context.Context, q Querier, workoutID int64) (json.RawMessage, error) {
+	var snap json.RawMessage
+	err := q.QueryRow(ctx, `SELECT `+snapshotExpr+` FROM workouts w WHERE w.id = $1`, workoutID).Scan(&snap)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return snap, err
+}
+
+// RecordAudit stores one event. Save and edit events whose before and after
+// snapshots match are dropped, so pressing "Save draft" twice logs once.
+func RecordAudit(ctx context.Context, q Querier, e AuditEvent) error {
+	if (e.Action == AuditSave || e.Action == AuditEdit) && e.Before != nil && bytes.Equal(e.Before, e.After) {
+		return nil
+	}
+	_, err := q.Exec(ctx,
+		`INSERT INTO audit_events(workout_id, action, actor, before, after) VALUES ($1, $2, $3, $4, $5)`,
+		e.WorkoutID, e.Action, e.Actor, nullJSON(e.Before), nullJSON(e.After))
+	return err
+}
+
+// MergeAudit records a save, edit or create event like RecordAudit, but
+// folds it into the workout's latest event when folds allows: the latest
+// event takes e's after snapshot and time. Autosaves use it so typing a
+// session in doesn't leave a snapshot per set.
+func MergeAudit(ctx context.Context, q Querier, e AuditEvent, window time.Duration) error {
+	if e.Before != nil && bytes.Equal(e.Before, e.After) {
+		return nil
+	}
+	var last AuditEvent
+	var before []byte
+	var now time.Time
+	err := q.QueryRow(ctx, `
+SELECT id, action, actor, created_at, before, now()
+FROM audit_events
+WHERE workout_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT 1
+FOR UPDATE`, e.WorkoutID).Scan(&last.ID, &last.Action, &last.Actor, &last.At, &before, &now)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return RecordAudit(ctx, q, e)
+	}
+	if err != nil {
+		return err
+	}
+	last.Before = before
+	if !folds(last, e, now, window) {
+		return RecordAudit(ctx, q, e)
+	}
+	if last.Before != nil && bytes.Equal(last.Before, e.After) {
+		// changes that undid each other leave nothing to record
+		_, err = q.Exec(ctx, `DELETE FROM audit_events WHERE id = $1`, last.ID)
+		return err
+	}
+	_, err = q.Exec(ctx, `UPDATE audit_events SET after = $2, created_at = now() WHERE id = $1`, last.ID, nullJSON(e.After))
+	return err
+}
+
+// folds reports whether e, made at now, can be folded into last: the same
+// actor made the same kind of change less than window before. A save also
+// folds into the create it follows, for the sets typed right after starting.
+func folds(last, e AuditEvent, now time.Time, window time.Duration) bool {
+	if last.Actor != e.Actor || now.Sub(last.At) >= window {
+		return false
+	}
+	return last.Action == e.Action || (e.Action == AuditSave && last.Action == AuditCreate)
+}
+
+// AuditPurgeTrash records a purge event for every trashed workout; call it
+// in the same transaction as the DELETE.
+func AuditPurgeTrash(ctx context.Context, q Querier, actor string) error {
+	_, err := q.Exec(ctx, `
+INSERT INTO audit_events(workout_id, action, actor, before)
+SELECT w.id, $1, $2, `+snapshotExpr+`
+FROM workouts w
+WHERE w.deleted_at IS NOT NULL`, AuditPurge, actor)
+	return err
+}
+
+// AuditEvents returns a workout's history, oldest first.
+func AuditEvents(ctx context.Context, pool *pgxpool.Pool, workoutID int64) ([]AuditEvent, error) {
+	rows, err := pool.Query(ctx, `
+SELECT id, workout_id, action, actor, created_at, before, after
+FROM audit_events
+WHERE workout_id = $1
+ORDER BY created_at, id`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AuditEvent
+	for rows.Next() {
+		var e AuditEvent
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.WorkoutID, &e.Action, &e.Actor, &e.At, &before, &after); err != nil {
+			return nil, err
+		}
+		e.Before, e.After = before, after
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func nullJSON(b json.RawMessage) any {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
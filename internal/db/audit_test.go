@@ -0,0 +1,32 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFolds(t *testing.T) {
+	now := time.Date(2026, 10, 18, 18, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+	tests := []struct {
+		name string
+		last AuditEvent
+		e    AuditEvent
+		want bool
+	}{
+		{"save after save", AuditEvent{Action: AuditSave, Actor: "ann", At: ago(time.Minute)}, AuditEvent{Action: AuditSave, Actor: "ann"}, true},
+		{"save after create", AuditEvent{Action: AuditCreate, Actor: "ann", At: ago(time.Minute)}, AuditEvent{Action: AuditSave, Actor: "ann"}, true},
+		{"edit after edit", AuditEvent{Action: AuditEdit, Actor: "ann", At: ago(time.Minute)}, AuditEvent{Action: AuditEdit, Actor: "ann"}, true},
+		{"just inside the window", AuditEvent{Action: AuditSave, Actor: "ann", At: ago(5*time.Minute - time.Second)}, AuditEvent{Action: AuditSave, Actor: "ann"}, true},
+		{"window passed", AuditEvent{Action: AuditSave, Actor: "ann", At: ago(5 * time.Minute)}, AuditEvent{Action: AuditSave, Actor: "ann"}, false},
+		{"another actor", AuditEvent{Action: AuditSave, Actor: "bob", At: ago(time.Minute)}, AuditEvent{Action: AuditSave, Actor: "ann"}, false},
+		{"edit after complete", AuditEvent{Action: AuditComplete, Actor: "ann", At: ago(time.Minute)}, AuditEvent{Action: AuditEdit, Actor: "ann"}, false},
+		{"save after restore", AuditEvent{Action: AuditRestore, Actor: "ann", At: ago(time.Minute)}, AuditEvent{Action: AuditSave, Actor: "ann"}, false},
+		{"edit after save", AuditEvent{Action: AuditSave, Actor: "ann", At: ago(time.Minute)}, AuditEvent{Action: AuditEdit, Actor: "ann"}, false},
+	}
+	for _, tt := range tests {
+		if got := folds(tt.last, tt.e, now, 5*time.Minute); got != tt.want {
+			t.Errorf("%s: folds = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
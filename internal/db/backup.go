@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// backupTables lists every table in the archive: all those the schema
+// creates, in its order, which puts parents before children so restore can
+// insert them without tripping foreign keys.
+var backupTables = schemaTables(schema)
+
+var createTable = regexp.MustCompile(`(?m)^CREATE TABLE IF NOT EXISTS (\w+)`)
+
+func schemaTables(sql string) []string {
+	var out []string
+	for _, m := range createTable.FindAllStringSubmatch(sql, -1) {
+		out = append(out, m[1])
+	}
+	return out
+}
+
+// Archive is a self-describing dump of the whole database.
+type Archive struct {
+	SchemaVersion int         `json:"schema_version"`
+	CreatedAt     time.Time   `json:"created_at"`
+	Tables        []TableDump `json:"tables"`
+}
+
+// TableDump holds one table's column names and rows (one JSON object per row).
+type TableDump struct {
+	Name    string            `json:"name"`
+	Columns []string          `json:"columns"`
+	Rows    []json.RawMessage `json:"rows"`
+}
+
+// Backup reads every table inside a single read-only snapshot.
+func Backup(ctx context.Context, pool *pgxpool.Pool) (*Archive, error) {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	a := &Archive{SchemaVersion: SchemaVersion, CreatedAt: time.Now().UTC()}
+	for _, name := range backupTables {
+		cols, err := tableColumns(ctx, tx, name)
+		if err != nil {
+			return nil, err
+		}
+		q := fmt.Sprintf(`SELECT row_to_json(t) FROM %s t ORDER BY 1`, pgx.Identifier{name}.Sanitize())
+		rows, err := tx.Query(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		d := TableDump{Name: name, Columns: cols, Rows: []json.RawMessage{}}
+		for rows.Next() {
+			var raw json.RawMessage
+			if err := rows.Scan(&raw); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			d.Rows = append(d.Rows, raw)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		a.Tables = append(a.Tables, d)
+	}
+	return a, tx.Commit(ctx)
+}
+
+// Restore loads an archive into an empty, already migrated database and
+// moves each id sequence past the restored rows.
+func Restore(ctx context.Context, pool *pgxpool.Pool, a *Archive) error {
+	if a.SchemaVersion > SchemaVersion {
+		return fmt.Errorf("archive schema version %d is newer than this build (%d)", a.SchemaVersion, SchemaVersion)
+	}
+	dumps := make(map[string]TableDump, len(a.Tables))
+	for _, d := range a.Tables {
+		dumps[d.Name] = d
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, name := range backupTables {
+		var n int64
+		if err := tx.QueryRow(ctx, fmt.Sprintf(`SELECT count(*) FROM %s`, pgx.Identifier{name}.Sanitize())).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("table %s is not empty; restore needs an empty database", name)
+		}
+	}
+
+	for _, name := range backupTables {
+		d, ok := dumps[name]
+		if !ok || len(d.Rows) == 0 {
+			continue
+		}
+		have, err := tableColumns(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		known := make(map[string]bool, len(have))
+		for _, c := range have {
+			known[c] = true
+		}
+		cols := make([]string, 0, len(d.Columns))
+		for _, c := range d.Columns {
+			if !known[c] {
+				return fmt.Errorf("table %s has no column %q", name, c)
+			}
+			cols = append(cols, pgx.Identifier{c}.Sanitize())
+		}
+		list := strings.Join(cols, ", ")
+		payload, err := json.Marshal(d.Rows)
+		if err != nil {
+			return err
+		}
+		tbl := pgx.Identifier{name}.Sanitize()
+		q := fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM json_populate_recordset(NULL::%s, $1::json)`, tbl, list, list, tbl)
+		if _, err := tx.Exec(ctx, q, string(payload)); err != nil {
+			return fmt.Errorf("restore %s: %w", name, err)
+		}
+		if !known["id"] {
+			continue // keyed by something else, e.g. reminders_sent by day
+		}
+		q = fmt.Sprintf(`SELECT setval(pg_get_serial_sequence($1, 'id'), COALESCE(max(id), 0) + 1, false) FROM %s`, tbl)
+		if _, err := tx.Exec(ctx, q, name); err != nil {
+			return fmt.Errorf("reset %s sequence: %w", name, err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func tableColumns(ctx context.Context, tx pgx.Tx, table string) ([]string, error) {
+	const q = `
+SELECT column_name
+FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name = $1
+ORDER BY ordinal_position`
+	rows, err := tx.Query(ctx, q, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cols []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBackupTables(t *testing.T) {
+	want := []string{"workouts", "exercises", "workout_items", "workout_circuits", "workout_rests", "audit_events", "reminders_sent"}
+	if !slices.Equal(backupTables, want) {
+		t.Fatalf("backupTables = %v, want %v", backupTables, want)
+	}
+	// restore inserts in this order, so referenced tables come first
+	for _, fk := range [][2]string{
+		{"workouts", "workout_items"}, {"exercises", "workout_items"},
+		{"workouts", "workout_circuits"}, {"workouts", "workout_rests"},
+	} {
+		if slices.Index(backupTables, fk[0]) > slices.Index(backupTables, fk[1]) {
+			t.Errorf("%s comes after %s, which references it", fk[0], fk[1])
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package db
+
+import "context"
+
+// CircuitRounds is one circuit block of a workout.
+type CircuitRounds struct {
+	Label     string
+	RoundsMin int
+	RoundsMax int
+	Completed int
+}
+
+// SyncCircuit tags the sets of a circuit's exercises (by planned label, so
+// swapped-in exercises count) with their round (the
+// set index: one set per exercise per round) and stores how many rounds
+// were done, counting every round with at least one logged set.
+func SyncCircuit(ctx context.Context, q Querier, workoutID int64, c CircuitRounds, members []string) error {
+	if _, err := q.Exec(ctx, `
+UPDATE workout_items SET round = set_index
+WHERE workout_id=$1 AND kind='sets' AND COALESCE(planned_label, label) = ANY($2) AND round IS DISTINCT FROM set_index`,
+		workoutID, members); err != nil {
+		return err
+	}
+	_, err := q.Exec(ctx, `
+INSERT INTO workout_circuits(workout_id, label, rounds_min, rounds_max, rounds_completed)
+SELECT $1, $2, $3, $4, count(DISTINCT round)
+FROM workout_items
+WHERE workout_id=$1 AND kind='sets' AND COALESCE(planned_label, label) = ANY($5) AND value_int IS NOT NULL
+ON CONFLICT (workout_id, label) DO UPDATE
+SET rounds_min = EXCLUDED.rounds_min, rounds_max = EXCLUDED.rounds_max, rounds_completed = EXCLUDED.rounds_completed`,
+		workoutID, c.Label, c.RoundsMin, c.RoundsMax, members)
+	return err
+}
+
+// TagSuperset records that labels were done as superset name in a workout.
+func TagSuperset(ctx context.Context, q Querier, workoutID int64, name string, labels []string) error {
+	_, err := q.Exec(ctx, `
+UPDATE workout_items SET superset = $2
+WHERE workout_id=$1 AND kind='sets' AND COALESCE(planned_label, label) = ANY($3) AND superset IS DISTINCT FROM $2`,
+		workoutID, name, labels)
+	return err
+}
+
+// Circuits returns a workout's circuit blocks.
+func Circuits(ctx context.Context, q Querier, workoutID int64) ([]CircuitRounds, error) {
+	rows, err := q.Query(ctx,
+		`SELECT label, rounds_min, rounds_max, rounds_completed FROM workout_circuits WHERE workout_id=$1 ORDER BY id`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []CircuitRounds
+	for rows.Next() {
+		var c CircuitRounds
+		if err := rows.Scan(&c.Label, &c.RoundsMin, &c.RoundsMax, &c.Completed); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
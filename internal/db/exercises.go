@@ -0,0 +1,229 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Exercise is a row of the exercise library.
+type Exercise struct {
+	ID        int64
+	Slug      string
+	Name      string
+	Aliases   []string
+	Primary   []string
+	Secondary []string
+	Equipment string
+	Unit      string // "reps" or "secs"
+}
+
+// exerciseFor is the id of the exercise a label (%[1]s) means: the one
+// named so, else the oldest with it as an alias, so an alias several
+// exercises share always resolves the same way. NULL if none matches.
+const exerciseFor = `(SELECT e.id FROM exercises e WHERE e.name = %[1]s OR %[1]s = ANY(e.aliases) ORDER BY e.name = %[1]s DESC, e.id LIMIT 1)`
+
+// SeedExercises inserts library entries whose slug is missing. Existing rows
+// are left alone so renames and merges survive restarts.
+func SeedExercises(ctx context.Context, q Querier, lib []Exercise) error {
+	for _, e := range lib {
+		if _, err := q.Exec(ctx, `
+INSERT INTO exercises(slug, name, aliases, primary_muscles, secondary_muscles, equipment, unit)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT DO NOTHING`,
+			e.Slug, e.Name, nonNil(e.Aliases), nonNil(e.Primary), nonNil(e.Secondary), e.Equipment, e.Unit); err != nil {
+			return fmt.Errorf("seed %s: %w", e.Slug, err)
+		}
+	}
+	return nil
+}
+
+// LinkExercises points the unlinked sets of a workout (of every workout when
+// workoutID is 0) at their exercise, matched by name or alias, and swapped
+// sets at the planned exercise too. Labels no exercise knows get a new one
+// of their own.
+func LinkExercises(ctx context.Context, q Querier, workoutID int64) error {
+	rows, err := q.Query(ctx, `
+SELECT DISTINCT wi.label FROM workout_items wi
+WHERE wi.kind = 'sets' AND wi.exercise_id IS NULL AND ($1 = 0 OR wi.workout_id = $1)
+  AND `+fmt.Sprintf(exerciseFor, "wi.label")+` IS NULL`, workoutID)
+	if err != nil {
+		return err
+	}
+	var unknown []string
+	for rows.Next() {
+		var l string
+		if err := rows.Scan(&l); err != nil {
+			rows.Close()
+			return err
+		}
+		unknown = append(unknown, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, l := range unknown {
+		if err := addExercise(ctx, q, l); err != nil {
+			return err
+		}
+	}
+
+	if _, err := q.Exec(ctx, `
+UPDATE workout_items wi SET exercise_id = `+fmt.Sprintf(exerciseFor, "wi.label")+`
+WHERE wi.kind = 'sets' AND wi.exercise_id IS NULL AND ($1 = 0 OR wi.workout_id = $1)`, workoutID); err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, `
+UPDATE workout_items wi SET planned_exercise_id = `+fmt.Sprintf(exerciseFor, "wi.planned_label")+`
+WHERE wi.kind = 'sets' AND wi.planned_label IS NOT NULL AND wi.planned_exercise_id IS NULL
+  AND ($1 = 0 OR wi.workout_id = $1)`, workoutID)
+	return err
+}
+
+// addExercise creates a bare exercise named name, numbering its slug if
+// another exercise already has it.
+func addExercise(ctx context.Context, q Querier, name string) error {
+	base := Slugify(name)
+	for n := 1; n <= 100; n++ {
+		slug := base
+		if n > 1 {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		tag, err := q.Exec(ctx, `INSERT INTO exercises(slug, name) VALUES ($1, $2) ON CONFLICT (slug) DO NOTHING`, slug, name)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 1 {
+			return nil
+		}
+	}
+	return fmt.Errorf("no free slug for %q", name)
+}
+
+var slugStrip = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify turns a name into a slug: "purp/red band pullups" → "purp-red-band-pullups".
+func Slugify(name string) string {
+	s := strings.Trim(slugStrip.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if s == "" {
+		return "exercise"
+	}
+	return s
+}
+
+// Exercises lists the library by name.
+func Exercises(ctx context.Context, pool *pgxpool.Pool) ([]Exercise, error) {
+	rows, err := pool.Query(ctx, `
+SELECT id, slug, name, aliases, primary_muscles, secondary_muscles, equipment, unit
+FROM exercises ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Exercise
+	for rows.Next() {
+		var e Exercise
+		if err := rows.Scan(&e.ID, &e.Slug, &e.Name, &e.Aliases, &e.Primary, &e.Secondary, &e.Equipment, &e.Unit); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// ErrNoExercise means no exercise has the given slug.
+var ErrNoExercise = errors.New("no such exercise")
+
+// RenameExercise changes an exercise's name, keeping the old one as an
+// alias so sets logged under it still match.
+func RenameExercise(ctx context.Context, pool *pgxpool.Pool, slug, name string) error {
+	tag, err := pool.Exec(ctx, `
+UPDATE exercises
+SET aliases = array_remove(array_append(array_remove(aliases, name), name), $2), name = $2
+WHERE slug = $1`, slug, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoExercise
+	}
+	return nil
+}
+
+// DescribeExercise sets the muscles, equipment and unit of an exercise,
+// typically one LinkExercises made up from a free-text label.
+func DescribeExercise(ctx context.Context, pool *pgxpool.Pool, e Exercise) error {
+	if e.Unit != "reps" && e.Unit != "secs" {
+		return fmt.Errorf("unit must be reps or secs, not %q", e.Unit)
+	}
+	tag, err := pool.Exec(ctx, `
+UPDATE exercises SET primary_muscles = $2, secondary_muscles = $3, equipment = $4, unit = $5
+WHERE slug = $1`, e.Slug, nonNil(e.Primary), nonNil(e.Secondary), e.Equipment, e.Unit)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoExercise
+	}
+	return nil
+}
+
+// MergeExercises folds exercise from into exercise into: its sets move over
+// and its name and aliases become aliases of into.
+func MergeExercises(ctx context.Context, pool *pgxpool.Pool, from, into string) error {
+	if from == into {
+		return fmt.Errorf("can't merge %s into itself", from)
+	}
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var fromID, intoID int64
+	var names []string
+	err = tx.QueryRow(ctx, `SELECT id, array_prepend(name, aliases) FROM exercises WHERE slug = $1 FOR UPDATE`, from).Scan(&fromID, &names)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrNoExercise, from)
+	}
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(ctx, `SELECT id FROM exercises WHERE slug = $1 FOR UPDATE`, into).Scan(&intoID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrNoExercise, into)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE workout_items SET exercise_id = $2 WHERE exercise_id = $1`, fromID, intoID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE workout_items SET planned_exercise_id = $2 WHERE planned_exercise_id = $1`, fromID, intoID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM exercises WHERE id = $1`, fromID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+UPDATE exercises
+SET aliases = ARRAY(SELECT DISTINCT a FROM unnest(aliases || $2::text[]) a WHERE a <> name ORDER BY a)
+WHERE id = $1`, intoID, names); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExerciseFor(t *testing.T) {
+	got := fmt.Sprintf(exerciseFor, "wi.label")
+	if strings.Contains(got, "%") || strings.Count(got, "wi.label") != 3 {
+		t.Errorf("exerciseFor(wi.label) = %s", got)
+	}
+	// exact names win over aliases, then the oldest exercise
+	if !strings.Contains(got, "ORDER BY e.name = wi.label DESC, e.id LIMIT 1") {
+		t.Errorf("exerciseFor doesn't pick one exercise deterministically: %s", got)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	for in, want := range map[string]string{
+		"purp/red band pullups": "purp-red-band-pullups",
+		"  Inc 2 Pushups ":      "inc-2-pushups",
+		"Ännchen's curls!":      "nnchen-s-curls",
+		"///":                   "exercise",
+	} {
+		if got := Slugify(in); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
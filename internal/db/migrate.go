@@ -6,6 +6,10 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// SchemaVersion identifies the shape of the schema below. Bump it whenever a
+// table or column is added so backups record what they were taken from.
+const SchemaVersion = 12
+
 const schema = `
 -- Ensure base tables exist (new installs get the full schema)
 CREATE TABLE IF NOT EXISTS workouts (
@@ -14,7 +18,28 @@ CREATE TABLE IF NOT EXISTS workouts (
   created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
   completed_at   TIMESTAMPTZ,
   session_date   DATE,
-  body_weight_kg NUMERIC(6,2)
+  body_weight_kg NUMERIC(6,2),
+  notes          TEXT,
+  deleted_at     TIMESTAMPTZ,
+  client_uuid    UUID,
+  revision       INT NOT NULL DEFAULT 0,
+  sync_hash      TEXT,
+  started_at     TIMESTAMPTZ,
+  ended_at       TIMESTAMPTZ,
+  deload         BOOLEAN NOT NULL DEFAULT false -- planned in a deload week
+);
+
+-- Exercise library; workout_items.exercise_id ties logged sets to it so
+-- history survives renames. Seeded from plan.Library at startup.
+CREATE TABLE IF NOT EXISTS exercises (
+  id                BIGSERIAL PRIMARY KEY,
+  slug              TEXT NOT NULL UNIQUE,
+  name              TEXT NOT NULL,
+  aliases           TEXT[] NOT NULL DEFAULT '{}',
+  primary_muscles   TEXT[] NOT NULL DEFAULT '{}',
+  secondary_muscles TEXT[] NOT NULL DEFAULT '{}',
+  equipment         TEXT NOT NULL DEFAULT '',
+  unit              TEXT NOT NULL DEFAULT 'reps' CHECK (unit IN ('reps','secs'))
 );
 
 CREATE TABLE IF NOT EXISTS workout_items (
@@ -25,17 +50,82 @@ CREATE TABLE IF NOT EXISTS workout_items (
   set_index  INT,
   value_int  INT,
   checked    BOOLEAN,
+  round      INT, -- circuit round the set was done in; NULL outside circuits
+  superset   TEXT, -- superset the set was alternated in, from plan.Item.Superset
+  exercise_id BIGINT REFERENCES exercises(id),
+  -- set when another exercise was swapped in for the planned one; label
+  -- and exercise_id then name the exercise actually performed
+  planned_label       TEXT,
+  planned_exercise_id BIGINT REFERENCES exercises(id),
   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
+-- Circuit blocks of a workout: rounds planned vs rounds actually done.
+CREATE TABLE IF NOT EXISTS workout_circuits (
+  id               BIGSERIAL PRIMARY KEY,
+  workout_id       BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+  label            TEXT   NOT NULL,
+  rounds_min       INT    NOT NULL,
+  rounds_max       INT    NOT NULL,
+  rounds_completed INT    NOT NULL DEFAULT 0
+);
+
+-- Rest actually taken after a set, as timed by the session page.
+CREATE TABLE IF NOT EXISTS workout_rests (
+  id           BIGSERIAL PRIMARY KEY,
+  workout_id   BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+  label        TEXT   NOT NULL,
+  set_index    INT    NOT NULL,
+  planned_secs INT    NOT NULL,
+  actual_secs  INT    NOT NULL,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- History of every change to a workout; kept after purges, so no FK.
+CREATE TABLE IF NOT EXISTS audit_events (
+  id         BIGSERIAL PRIMARY KEY,
+  workout_id BIGINT NOT NULL,
+  action     TEXT   NOT NULL,
+  actor      TEXT   NOT NULL DEFAULT '',
+  before     JSONB,
+  after      JSONB,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Days a training reminder went out, so a restart doesn't send it twice.
+CREATE TABLE IF NOT EXISTS reminders_sent (
+  day     DATE PRIMARY KEY,
+  sent_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
 -- Backfill columns for existing installs
 ALTER TABLE workouts ADD COLUMN IF NOT EXISTS session_date DATE;
 ALTER TABLE workouts ADD COLUMN IF NOT EXISTS body_weight_kg NUMERIC(6,2);
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS notes TEXT;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS client_uuid UUID;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 0;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS sync_hash TEXT;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS ended_at TIMESTAMPTZ;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS deload BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE workout_items ADD COLUMN IF NOT EXISTS round INT;
+ALTER TABLE workout_items ADD COLUMN IF NOT EXISTS superset TEXT;
+ALTER TABLE workout_items ADD COLUMN IF NOT EXISTS exercise_id BIGINT REFERENCES exercises(id);
+ALTER TABLE workout_items ADD COLUMN IF NOT EXISTS planned_label TEXT;
+ALTER TABLE workout_items ADD COLUMN IF NOT EXISTS planned_exercise_id BIGINT REFERENCES exercises(id);
 
 -- Indexes (safe to create now that columns exist)
 CREATE INDEX IF NOT EXISTS idx_workout_items_workout_id ON workout_items(workout_id);
 CREATE INDEX IF NOT EXISTS idx_workout_items_label ON workout_items(label);
+CREATE INDEX IF NOT EXISTS idx_workout_items_exercise_id ON workout_items(exercise_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_name ON exercises(name);
 CREATE INDEX IF NOT EXISTS idx_workouts_session_date ON workouts(session_date);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workouts_client_uuid ON workouts(client_uuid);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workout_circuits_label ON workout_circuits(workout_id, label);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workout_rests_set ON workout_rests(workout_id, label, set_index);
+CREATE INDEX IF NOT EXISTS idx_audit_events_workout_id ON audit_events(workout_id);
+CREATE INDEX IF NOT EXISTS idx_workouts_deleted_at ON workouts(deleted_at) WHERE deleted_at IS NOT NULL;
 `
 
 func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
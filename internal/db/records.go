@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Record is an exercise's best set of a date range that beat every set
+// logged before the range.
+type Record struct {
+	Exercise  string // exercise name, or the label if the set isn't linked
+	Unit      string // "reps" or "secs"
+	Value     int
+	Previous  int    // best set before the range
+	WorkoutID int64  // the workout the record was set in
+	Date      string // its local day, "2006-01-02"
+}
+
+// Records returns the records set by live completed workouts dated in
+// [from, to), both local midnights, by exercise name. Workouts are dated as
+// LoggedSets dates them; an exercise's first ever sets set no record.
+func Records(ctx context.Context, q Querier, from, to time.Time) ([]Record, error) {
+	best, err := bestSets(ctx, q, from)
+	if err != nil {
+		return nil, err
+	}
+	sets, err := LoggedSets(ctx, q, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return beating(best, sets, from.Location()), nil
+}
+
+// WorkoutRecords returns the records workout id set on day, its local
+// midnight: its sets that beat every set logged before it, those of the
+// day's workouts completed earlier included.
+func WorkoutRecords(ctx context.Context, q Querier, id int64, day time.Time) ([]Record, error) {
+	best, err := bestSets(ctx, q, day)
+	if err != nil {
+		return nil, err
+	}
+	sets, err := LoggedSets(ctx, q, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	return workoutRecords(best, sets, id, day.Location()), nil
+}
+
+// workoutRecords folds the sets of workouts completed before workout id into
+// best, then finds the records among id's own sets.
+func workoutRecords(best map[string]int, sets []LoggedSet, id int64, loc *time.Location) []Record {
+	var mine []LoggedSet
+	for _, s := range sets {
+		if s.WorkoutID == id {
+			mine = append(mine, s)
+		}
+	}
+	if len(mine) == 0 {
+		return nil
+	}
+	done := mine[0].CompletedAt
+	for _, s := range sets {
+		if s.WorkoutID == id || !s.CompletedAt.Before(done) {
+			continue
+		}
+		if pb, seen := best[s.Exercise]; !seen || s.Value > pb {
+			best[s.Exercise] = s.Value
+		}
+	}
+	return beating(best, mine, loc)
+}
+
+// beating is each exercise's best set among sets if it beats best, by
+// exercise name. An exercise with nothing in best sets no record.
+func beating(best map[string]int, sets []LoggedSet, loc *time.Location) []Record {
+	recs := map[string]*Record{}
+	for _, s := range sets {
+		pb, seen := best[s.Exercise]
+		if !seen || s.Value <= pb {
+			continue
+		}
+		if r := recs[s.Exercise]; r != nil && s.Value <= r.Value {
+			continue
+		}
+		recs[s.Exercise] = &Record{Exercise: s.Exercise, Unit: s.Unit, Value: s.Value, Previous: pb, WorkoutID: s.WorkoutID, Date: s.Day(loc)}
+	}
+	out := make([]Record, 0, len(recs))
+	for _, r := range recs {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Exercise < out[j].Exercise })
+	return out
+}
+
+// bestSets is each exercise's best set in live completed workouts dated
+// before before, a local midnight.
+func bestSets(ctx context.Context, q Querier, before time.Time) (map[string]int, error) {
+	rows, err := q.Query(ctx, `
+SELECT COALESCE(e.name, wi.label), MAX(wi.value_int)
+FROM workout_items wi
+JOIN workouts w ON w.id = wi.workout_id
+LEFT JOIN exercises e ON e.id = wi.exercise_id
+WHERE wi.kind = 'sets'
+	AND wi.value_int IS NOT NULL
+	AND w.completed_at IS NOT NULL
+	AND w.deleted_at IS NULL
+	AND ((w.session_date IS NOT NULL AND w.session_date < $1::date)
+	  OR (w.session_date IS NULL AND w.completed_at < $2))
+GROUP BY 1`,
+		before.Format("2006-01-02"), before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	best := map[string]int{}
+	for rows.Next() {
+		var name string
+		var v int
+		if err := rows.Scan(&name, &v); err != nil {
+			return nil, err
+		}
+		best[name] = v
+	}
+	return best, rows.Err()
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWorkoutRecords(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2026, 10, 18, h, 0, 0, 0, time.UTC) }
+	set := func(w int64, done time.Time, ex string, v int) LoggedSet {
+		return LoggedSet{WorkoutID: w, CompletedAt: done, Exercise: ex, Unit: "reps", Value: v}
+	}
+	// three workouts on one day, in LoggedSets order
+	sets := []LoggedSet{
+		set(1, at(8), "plank", 60), // first ever plank
+		set(1, at(8), "pushups", 22),
+		set(2, at(18), "plank", 90),
+		set(2, at(18), "pushups", 21),
+		set(2, at(18), "pushups", 23),
+		set(2, at(18), "rows", 12),
+		set(3, at(20), "pushups", 30),
+	}
+	before := func() map[string]int { return map[string]int{"pushups": 20, "rows": 12} }
+	rec := func(w int64, ex string, v, prev int) Record {
+		return Record{Exercise: ex, Unit: "reps", Value: v, Previous: prev, WorkoutID: w, Date: "2026-10-18"}
+	}
+
+	tests := []struct {
+		id   int64
+		want []Record
+	}{
+		{1, []Record{rec(1, "pushups", 22, 20)}},
+		// beats the morning's sets, not just those of earlier days; the
+		// evening's 30 doesn't count against it
+		{2, []Record{rec(2, "plank", 90, 60), rec(2, "pushups", 23, 22)}},
+		{3, []Record{rec(3, "pushups", 30, 23)}},
+		{9, nil},
+	}
+	for _, tt := range tests {
+		got := workoutRecords(before(), sets, tt.id, time.UTC)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("workout %d: %+v, want %+v", tt.id, got, tt.want)
+		}
+	}
+
+	// over the whole day only the day's best counts, against earlier days
+	got := beating(before(), sets, time.UTC)
+	if want := []Record{rec(3, "pushups", 30, 20)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("beating = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// ClaimReminder records that day's reminder is being sent. It reports false
+// if it already was, by this process or one before a restart.
+func ClaimReminder(ctx context.Context, q Querier, day time.Time) (bool, error) {
+	tag, err := q.Exec(ctx, `INSERT INTO reminders_sent(day) VALUES ($1::date) ON CONFLICT DO NOTHING`, day.Format("2006-01-02"))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseReminder forgets a claim whose reminder could not be sent.
+func ReleaseReminder(ctx context.Context, q Querier, day time.Time) error {
+	_, err := q.Exec(ctx, `DELETE FROM reminders_sent WHERE day = $1::date`, day.Format("2006-01-02"))
+	return err
+}
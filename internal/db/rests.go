@@ -0,0 +1,37 @@
+package db
+
+import "context"
+
+// RecordRest stores the rest taken after set setIndex of label. Timing the
+// same set again replaces the earlier value.
+func RecordRest(ctx context.Context, q Querier, workoutID int64, label string, setIndex, plannedSecs, actualSecs int) error {
+	_, err := q.Exec(ctx, `
+INSERT INTO workout_rests(workout_id, label, set_index, planned_secs, actual_secs)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (workout_id, label, set_index)
+DO UPDATE SET planned_secs = EXCLUDED.planned_secs, actual_secs = EXCLUDED.actual_secs, created_at = now()`,
+		workoutID, label, setIndex, plannedSecs, actualSecs)
+	return err
+}
+
+// RestsByLabel returns a workout's recorded rests in seconds, per label in
+// set order.
+func RestsByLabel(ctx context.Context, q Querier, workoutID int64) (map[string][]int, error) {
+	rows, err := q.Query(ctx,
+		`SELECT label, actual_secs FROM workout_rests WHERE workout_id=$1 ORDER BY label, set_index`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string][]int{}
+	for rows.Next() {
+		var label string
+		var secs int
+		if err := rows.Scan(&label, &secs); err != nil {
+			return nil, err
+		}
+		out[label] = append(out[label], secs)
+	}
+	return out, rows.Err()
+}
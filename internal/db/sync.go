@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SyncState is what the sync endpoint needs to know about a workout before
+// applying a client's queued change to it.
+type SyncState struct {
+	ID          int64
+	Revision    int
+	SyncHash    string
+	CompletedAt *time.Time
+	DeletedAt   *time.Time
+}
+
+// WorkoutByClientUUID locks and returns the workout created under a client
+// UUID; ok is false if there is none yet.
+func WorkoutByClientUUID(ctx context.Context, tx pgx.Tx, uuid string) (st SyncState, ok bool, err error) {
+	var hash *string
+	err = tx.QueryRow(ctx, `
+SELECT id, revision, sync_hash, completed_at, deleted_at
+FROM workouts
+WHERE client_uuid = $1::uuid
+FOR UPDATE`, uuid).Scan(&st.ID, &st.Revision, &hash, &st.CompletedAt, &st.DeletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return st, false, nil
+	}
+	if err != nil {
+		return st, false, err
+	}
+	if hash != nil {
+		st.SyncHash = *hash
+	}
+	return st, true, nil
+}
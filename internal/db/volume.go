@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// LoggedSet is one set of a completed workout, with what the exercises
+// table knows about its exercise.
+type LoggedSet struct {
+	WorkoutID   int64
+	SessionDate *time.Time
+	CompletedAt time.Time
+	Exercise    string // exercise name, or the label if the set isn't linked
+	Unit        string // "reps" or "secs"
+	Primary     []string
+	Secondary   []string
+	SetIndex    int
+	Value       int
+}
+
+// Day is the local day the set's workout counts towards: its session date,
+// else the day it was completed in loc.
+func (s LoggedSet) Day(loc *time.Location) string {
+	if s.SessionDate != nil {
+		return s.SessionDate.Format("2006-01-02") // DATE comes back as UTC midnight
+	}
+	return s.CompletedAt.In(loc).Format("2006-01-02")
+}
+
+// LoggedSets returns the sets of live completed workouts dated in [since,
+// until), both local midnights: by session date, else by completion time.
+// Oldest first.
+func LoggedSets(ctx context.Context, q Querier, since, until time.Time) ([]LoggedSet, error) {
+	rows, err := q.Query(ctx, `
+SELECT w.id, w.session_date, w.completed_at,
+	COALESCE(e.name, wi.label), COALESCE(e.unit, 'reps'),
+	COALESCE(e.primary_muscles, '{}'), COALESCE(e.secondary_muscles, '{}'),
+	wi.set_index, wi.value_int
+FROM workout_items wi
+JOIN workouts w ON w.id = wi.workout_id
+LEFT JOIN exercises e ON e.id = wi.exercise_id
+WHERE wi.kind = 'sets'
+	AND wi.value_int IS NOT NULL
+	AND w.completed_at IS NOT NULL
+	AND w.deleted_at IS NULL
+	AND ((w.session_date IS NOT NULL AND w.session_date >= $1::date AND w.session_date < $3::date)
+	  OR (w.session_date IS NULL AND w.completed_at >= $2 AND w.completed_at < $4))
+ORDER BY COALESCE(w.session_date, w.completed_at::date), w.completed_at, wi.label, wi.set_index`,
+		since.Format("2006-01-02"), since, until.Format("2006-01-02"), until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []LoggedSet
+	for rows.Next() {
+		var s LoggedSet
+		if err := rows.Scan(&s.WorkoutID, &s.SessionDate, &s.CompletedAt, &s.Exercise, &s.Unit,
+			&s.Primary, &s.Secondary, &s.SetIndex, &s.Value); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
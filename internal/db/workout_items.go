@@ -2,34 +2,46 @@ package db
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // PrevLatestByLabels returns the most recent completed values for each label.
-// Values are ordered by set_index (e.g., [10, 6, 3, 0]).
-func PrevLatestByLabels(ctx context.Context, pool *pgxpool.Pool, labels []string) (map[string][]int, error) {
+// Labels are resolved to their exercise by name or alias, so sets logged
+// under an older name count too. Values are ordered by set_index (e.g.,
+// [10, 6, 3, 0]).
+func PrevLatestByLabels(ctx context.Context, q Querier, labels []string) (map[string][]int, error) {
 	out := make(map[string][]int)
 	if len(labels) == 0 {
 		return out, nil
 	}
-	const q = `
-WITH latest AS (
-	SELECT wi.label, max(w.completed_at) AS maxc
-	FROM workout_items wi
+	sql := `
+WITH wanted AS (
+	SELECT label, exercise_id FROM (
+		SELECT l.label, ` + fmt.Sprintf(exerciseFor, "l.label") + ` AS exercise_id
+		FROM (SELECT DISTINCT unnest($1::text[])) AS l(label)
+	) m
+	WHERE exercise_id IS NOT NULL
+),
+latest AS (
+	SELECT wa.label, wa.exercise_id, max(w.completed_at) AS maxc
+	FROM wanted wa
+	JOIN workout_items wi ON wi.exercise_id = wa.exercise_id
 	JOIN workouts w ON w.id = wi.workout_id
 	WHERE w.completed_at IS NOT NULL
+		AND w.deleted_at IS NULL
 		AND wi.kind = 'sets'
-		AND wi.label = ANY($1)
-	GROUP BY wi.label
+	GROUP BY wa.label, wa.exercise_id
 )
-SELECT wi.label, wi.set_index, wi.value_int
-FROM workout_items wi
-JOIN workouts w ON w.id = wi.workout_id
-JOIN latest l ON l.label = wi.label AND w.completed_at = l.maxc
-ORDER BY wi.label, wi.set_index;
+SELECT l.label, wi.set_index, wi.value_int
+FROM latest l
+JOIN workout_items wi ON wi.exercise_id = l.exercise_id AND wi.kind = 'sets'
+JOIN workouts w ON w.id = wi.workout_id AND w.completed_at = l.maxc
+WHERE w.deleted_at IS NULL
+ORDER BY l.label, wi.set_index;
 `
-	rows, err := pool.Query(ctx, q, labels)
+	rows, err := q.Query(ctx, sql, labels)
 	if err != nil {
 		return nil, err
 	}
@@ -49,3 +61,26 @@ ORDER BY wi.label, wi.set_index;
 	}
 	return out, rows.Err()
 }
+
+// ItemLabels returns every distinct label logged in a live workout, sorted.
+func ItemLabels(ctx context.Context, pool *pgxpool.Pool) ([]string, error) {
+	rows, err := pool.Query(ctx, `
+SELECT DISTINCT wi.label
+FROM workout_items wi
+JOIN workouts w ON w.id = wi.workout_id
+WHERE w.deleted_at IS NULL
+ORDER BY wi.label`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var l string
+		if err := rows.Scan(&l); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
@@ -2,12 +2,16 @@ package db
 
 import (
 	"context"
+	"errors"
+	"math"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func LastCompletedDay(ctx context.Context, pool *pgxpool.Pool) (int, bool, error) {
-	const q = `SELECT day_num FROM workouts WHERE completed_at IS NOT NULL ORDER BY completed_at DESC LIMIT 1`
+	const q = `SELECT day_num FROM workouts WHERE completed_at IS NOT NULL AND deleted_at IS NULL ORDER BY completed_at DESC LIMIT 1`
 	var day int
 	err := pool.QueryRow(ctx, q).Scan(&day)
 	if err != nil {
@@ -17,10 +21,74 @@ func LastCompletedDay(ctx context.Context, pool *pgxpool.Pool) (int, bool, error
 	return day, true, nil
 }
 
-func NextDay(ctx context.Context, pool *pgxpool.Pool) int {
+// Next is the session the rotation expects next.
+type Next struct {
+	Day        int       // rotation day, 1-12
+	Week       int       // week of the training block "on" falls in, from 0
+	BlockStart time.Time // local midnight the blocks are counted from
+}
+
+// WeekOf is the week of the training block d falls in, from 0.
+func (n Next) WeekOf(d time.Time) int {
+	days := int(math.Round(d.Sub(n.BlockStart).Hours() / 24))
+	if days < 0 {
+		return 0
+	}
+	return days / 7
+}
+
+// NextDay returns the rotation day after the last completed one (1 to
+// start) and the week of the training block that date on falls in. Blocks
+// are counted from blockStart, or when that is zero from the day of the
+// first completed workout (else from on).
+func NextDay(ctx context.Context, pool *pgxpool.Pool, blockStart, on time.Time) Next {
+	n := Next{Day: 1, BlockStart: blockStart}
 	if d, ok, _ := LastCompletedDay(ctx, pool); ok {
-		n := (d % 12) + 1
-		return n
+		n.Day = (d % 12) + 1
+	}
+	if n.BlockStart.IsZero() {
+		n.BlockStart = time.Date(on.Year(), on.Month(), on.Day(), 0, 0, 0, 0, on.Location())
+		if d, ok, err := FirstCompletedDate(ctx, pool, on.Location()); err == nil && ok {
+			n.BlockStart = d
+		}
+	}
+	n.Week = n.WeekOf(on)
+	return n
+}
+
+// FirstCompletedDate is the local day of the earliest completed workout: its
+// session date, else the day it was completed.
+func FirstCompletedDate(ctx context.Context, q Querier, loc *time.Location) (time.Time, bool, error) {
+	var sd *time.Time
+	var ct time.Time
+	err := q.QueryRow(ctx, `
+SELECT session_date, completed_at FROM workouts
+WHERE completed_at IS NOT NULL AND deleted_at IS NULL
+ORDER BY COALESCE(session_date, completed_at::date), completed_at LIMIT 1`).Scan(&sd, &ct)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
 	}
-	return 1
+	// DATE columns come back as UTC midnight
+	d := ct.In(loc)
+	if sd != nil {
+		d = *sd
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc), true, nil
+}
+
+// StartedOn reports whether a workout, finished or not, exists for the
+// local day [day, day+24h): dated that day, or undated and started in it.
+func StartedOn(ctx context.Context, q Querier, day time.Time) (bool, error) {
+	var found bool
+	err := q.QueryRow(ctx, `
+SELECT EXISTS (
+  SELECT 1 FROM workouts
+  WHERE deleted_at IS NULL
+    AND (session_date = $1::date
+         OR (session_date IS NULL AND COALESCE(started_at, created_at) >= $2 AND COALESCE(started_at, created_at) < $3))
+)`, day.Format("2006-01-02"), day, day.AddDate(0, 0, 1)).Scan(&found)
+	return found, err
 }
@@ -0,0 +1,117 @@
+package plan
+
+// Exercise is an entry of the exercise library. Slug is the canonical id
+// plan items and logged sets refer to; Name is what the form shows, and
+// Aliases are older or alternative names that map to the same exercise.
+type Exercise struct {
+	Slug      string
+	Name      string
+	Aliases   []string
+	Primary   []string // muscle groups doing most of the work
+	Secondary []string
+	Equipment string // "none", "band", "bench", ...
+	Unit      string // "reps" or "secs"
+
+	// Substitutes are slugs of exercises the session form offers in its
+	// place, e.g. when the band is missing.
+	Substitutes []string
+}
+
+// Push and pull muscle groups. A set whose exercise works one of them as
+// a primary muscle counts towards that side of the push/pull balance.
+var (
+	PushMuscles = []string{"chest", "triceps"}
+	PullMuscles = []string{"back", "biceps"}
+)
+
+// Library seeds the exercises table. Entries are only inserted when their
+// slug is missing, so renames and merges made in the database stick.
+var Library = []Exercise{
+	{Slug: "inc-2-pushups", Name: "inc 2 pushups", Primary: []string{"chest", "triceps"}, Secondary: []string{"shoulders", "core"}, Equipment: "bench", Unit: "reps",
+		Substitutes: []string{"knee-pushups"}},
+	{Slug: "green-rows", Name: "green rows", Primary: []string{"back", "biceps"}, Secondary: []string{"shoulders"}, Equipment: "band", Unit: "reps",
+		Substitutes: []string{"inverted-rows"}},
+	{Slug: "bw-squats", Name: "bw squats", Primary: []string{"quads", "glutes"}, Secondary: []string{"hamstrings"}, Equipment: "none", Unit: "reps",
+		Substitutes: []string{"bw-split-squats", "lunges"}},
+	{Slug: "plank", Name: "plank", Primary: []string{"core"}, Secondary: []string{"shoulders"}, Equipment: "none", Unit: "secs"},
+	{Slug: "knee-raises", Name: "knee raises", Primary: []string{"core"}, Equipment: "none", Unit: "reps"},
+	{Slug: "purp-red-band-pullups", Name: "purp/red band pullups", Primary: []string{"back", "biceps"}, Secondary: []string{"forearms", "core"}, Equipment: "band", Unit: "reps",
+		Substitutes: []string{"inverted-rows", "green-rows"}},
+	{Slug: "bw-split-squats", Name: "bw split squats", Primary: []string{"quads", "glutes"}, Secondary: []string{"hamstrings"}, Equipment: "none", Unit: "reps",
+		Substitutes: []string{"lunges", "bw-squats"}},
+	{Slug: "green-face-pulls", Name: "green face pulls", Primary: []string{"shoulders", "back"}, Equipment: "band", Unit: "reps",
+		Substitutes: []string{"prone-y-raises"}},
+	{Slug: "band-curls", Name: "band curls", Primary: []string{"biceps"}, Secondary: []string{"forearms"}, Equipment: "band", Unit: "reps"},
+	{Slug: "purple-dips", Name: "purple dips", Primary: []string{"triceps", "chest"}, Secondary: []string{"shoulders"}, Equipment: "band", Unit: "reps",
+		Substitutes: []string{"bench-dips"}},
+
+	// substitutes only
+	{Slug: "knee-pushups", Name: "knee pushups", Primary: []string{"chest", "triceps"}, Secondary: []string{"shoulders"}, Equipment: "none", Unit: "reps"},
+	{Slug: "inverted-rows", Name: "inverted rows", Primary: []string{"back", "biceps"}, Secondary: []string{"shoulders", "core"}, Equipment: "table", Unit: "reps"},
+	{Slug: "lunges", Name: "lunges", Primary: []string{"quads", "glutes"}, Secondary: []string{"hamstrings"}, Equipment: "none", Unit: "reps"},
+	{Slug: "prone-y-raises", Name: "prone Y raises", Primary: []string{"shoulders", "back"}, Equipment: "none", Unit: "reps"},
+	{Slug: "bench-dips", Name: "bench dips", Primary: []string{"triceps"}, Secondary: []string{"chest", "shoulders"}, Equipment: "bench", Unit: "reps"},
+}
+
+// ExerciseByName finds the library entry named or aliased name.
+func ExerciseByName(name string) (Exercise, bool) {
+	for _, e := range Library {
+		if e.Name == name {
+			return e, true
+		}
+		for _, a := range e.Aliases {
+			if a == name {
+				return e, true
+			}
+		}
+	}
+	return Exercise{}, false
+}
+
+// ExerciseBySlug finds the library entry with the given slug.
+func ExerciseBySlug(slug string) (Exercise, bool) {
+	for _, e := range Library {
+		if e.Slug == slug {
+			return e, true
+		}
+	}
+	return Exercise{}, false
+}
+
+// Substitutes lists the exercises the form offers in place of it.
+func Substitutes(it Item) []Exercise {
+	e, ok := ExerciseBySlug(it.Exercise)
+	if !ok {
+		return nil
+	}
+	var out []Exercise
+	for _, s := range e.Substitutes {
+		if sub, ok := ExerciseBySlug(s); ok {
+			out = append(out, sub)
+		}
+	}
+	return out
+}
+
+// CanSwap reports whether name may be logged in place of it.
+func CanSwap(it Item, name string) bool {
+	for _, s := range Substitutes(it) {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// withExercises fills in Exercise on the "sets" items the library knows.
+func withExercises(items []Item) []Item {
+	for i := range items {
+		if items[i].Kind != "sets" || items[i].Exercise != "" {
+			continue
+		}
+		if e, ok := ExerciseByName(items[i].Label); ok {
+			items[i].Exercise = e.Slug
+		}
+	}
+	return items
+}
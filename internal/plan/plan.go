@@ -1,5 +1,10 @@
 package plan
 
+import (
+	"fmt"
+	"math"
+)
+
 type Item struct {
 	Kind    string // "check" or "sets" or "heading"
 	Label   string
@@ -7,9 +12,67 @@ type Item struct {
 	RepsMin int
 	RepsMax int
 	Note    string // optional suffix like "(20-60 secs)"
+
+	Exercise string // slug of the Library exercise a "sets" item logs
+
+	// Circuit marks a "heading" that opens a circuit block and the "sets"
+	// items that follow it as the block's exercises. Each exercise does one
+	// set per round, so its Sets equals the heading's RoundsMax.
+	Circuit   bool
+	RoundsMin int // planned rounds of a circuit heading, e.g. "3-4 circuits"
+	RoundsMax int
+
+	// Superset groups "sets" items done back to back, alternating set by
+	// set; items sharing a non-empty Superset are rendered together.
+	Superset string
+
+	RestSecs     int // rest between sets (between rounds for a circuit heading); 0 means the server default
+	IntervalSecs int // EMOM slot length for a circuit heading; 0 means the server default
+}
+
+// Circuit is one circuit block of a day: the heading's index in the day's
+// items and the indexes of its exercises.
+type Circuit struct {
+	Heading int
+	Members []int
+}
+
+// Circuits finds the circuit blocks in items.
+func Circuits(items []Item) []Circuit {
+	var out []Circuit
+	for i := 0; i < len(items); i++ {
+		if items[i].Kind != "heading" || !items[i].Circuit {
+			continue
+		}
+		c := Circuit{Heading: i}
+		for i+1 < len(items) && items[i+1].Kind == "sets" && items[i+1].Circuit {
+			i++
+			c.Members = append(c.Members, i)
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// CircuitOf returns the heading of the circuit that label is done in on
+// rotation day n, if any.
+func CircuitOf(n int, label string) (Item, bool) {
+	items := Day(n)
+	for _, c := range Circuits(items) {
+		for _, m := range c.Members {
+			if items[m].Label == label {
+				return items[c.Heading], true
+			}
+		}
+	}
+	return Item{}, false
 }
 
 func Day(n int) []Item {
+	return withExercises(day(n))
+}
+
+func day(n int) []Item {
 	switch n {
 	case 1:
 		return strengthA("green face pulls", true, false)
@@ -30,14 +93,75 @@ func Day(n int) []Item {
 	}
 }
 
+// Timer defaults, used when an item doesn't set its own and the server
+// isn't configured otherwise.
+const (
+	DefaultRestSecs     = 90
+	DefaultIntervalSecs = 60
+)
+
+// Fallback limits for labels the plan doesn't know.
+const (
+	UnplannedMaxSets  = 10
+	UnplannedMaxValue = 500
+)
+
+// MaxExtraSets is how many sets past the plan a "sets" item may be given
+// during a session.
+const MaxExtraSets = 3
+
+// MaxValue is the largest set value accepted for a "sets" item. It leaves
+// plenty of room above RepsMax; anything bigger is almost surely a typo.
+func (it Item) MaxValue() int {
+	if it.RepsMax <= 0 {
+		return UnplannedMaxValue
+	}
+	return 4 * it.RepsMax
+}
+
+// Find returns the item labelled label on rotation day n.
+func Find(n int, label string) (Item, bool) {
+	for _, it := range Day(n) {
+		if it.Label == label {
+			return it, true
+		}
+	}
+	return Item{}, false
+}
+
+// DayType names the session template used on rotation day n.
+func DayType(n int) string {
+	switch n {
+	case 1, 5, 9:
+		return "strength A"
+	case 3, 7, 11:
+		return "strength B"
+	default:
+		return "easy"
+	}
+}
+
 func commonStarts() []Item {
 	return []Item{
 		{Kind: "check", Label: "foam roll"},
 		{Kind: "check", Label: "walk 55 mins 1%, 5 mins 0%"},
-		{Kind: "heading", Label: "3-4 circuits"},
 	}
 }
 
+// circuitHeading opens the circuit done after the superset.
+func circuitHeading() Item {
+	return Item{Kind: "heading", Label: "3-4 circuits", Circuit: true, RoundsMin: 3, RoundsMax: 4}
+}
+
+// circuit marks exercises as belonging to the circuit opened by the
+// heading just before them.
+func circuit(exercises []Item) []Item {
+	for i := range exercises {
+		exercises[i].Circuit = true
+	}
+	return exercises
+}
+
 func easyDay() []Item {
 	return []Item{
 		{Kind: "check", Label: "foam roll"},
@@ -50,16 +174,20 @@ func easyDay() []Item {
 func strengthA(finisher string, includePlank bool, includeKneeRaises bool) []Item {
 	items := append([]Item{}, commonStarts()...)
 	items = append(items,
-		Item{Kind: "sets", Label: "inc 2 pushups", Sets: 4, RepsMin: 8, RepsMax: 15},
-		Item{Kind: "sets", Label: "green rows", Sets: 4, RepsMin: 8, RepsMax: 12},
-		Item{Kind: "sets", Label: "bw squats", Sets: 4, RepsMin: 10, RepsMax: 15},
+		Item{Kind: "sets", Label: "inc 2 pushups", Sets: 4, RepsMin: 8, RepsMax: 15, Superset: "A"},
+		Item{Kind: "sets", Label: "green rows", Sets: 4, RepsMin: 8, RepsMax: 12, Superset: "A"},
+		circuitHeading(),
 	)
+	exercises := []Item{
+		{Kind: "sets", Label: "bw squats", Sets: 4, RepsMin: 10, RepsMax: 15},
+	}
 	if includePlank {
-		items = append(items, Item{Kind: "sets", Label: "plank", Sets: 4, RepsMin: 20, RepsMax: 60, Note: "secs"})
+		exercises = append(exercises, Item{Kind: "sets", Label: "plank", Sets: 4, RepsMin: 20, RepsMax: 60, Note: "secs", RestSecs: 60})
 	}
 	if includeKneeRaises {
-		items = append(items, Item{Kind: "sets", Label: "knee raises", Sets: 4, RepsMin: 6, RepsMax: 12})
+		exercises = append(exercises, Item{Kind: "sets", Label: "knee raises", Sets: 4, RepsMin: 6, RepsMax: 12})
 	}
+	items = append(items, circuit(exercises)...)
 	items = append(items,
 		Item{Kind: "sets", Label: finisher, Sets: 1, RepsMin: 12, RepsMax: 20, Note: "finisher"},
 		Item{Kind: "check", Label: "stretch"},
@@ -74,13 +202,17 @@ func strengthA(finisher string, includePlank bool, includeKneeRaises bool) []Ite
 func strengthB(finisher string, includeKneeRaises bool) []Item {
 	items := append([]Item{}, commonStarts()...)
 	items = append(items,
-		Item{Kind: "sets", Label: "inc 2 pushups", Sets: 4, RepsMin: 8, RepsMax: 15},
-		Item{Kind: "sets", Label: "purp/red band pullups", Sets: 4, RepsMin: 6, RepsMax: 10},
-		Item{Kind: "sets", Label: "bw split squats", Sets: 4, RepsMin: 10, RepsMax: 15},
+		Item{Kind: "sets", Label: "inc 2 pushups", Sets: 4, RepsMin: 8, RepsMax: 15, Superset: "A"},
+		Item{Kind: "sets", Label: "purp/red band pullups", Sets: 4, RepsMin: 6, RepsMax: 10, Superset: "A"},
+		circuitHeading(),
 	)
+	exercises := []Item{
+		{Kind: "sets", Label: "bw split squats", Sets: 4, RepsMin: 10, RepsMax: 15},
+	}
 	if includeKneeRaises {
-		items = append(items, Item{Kind: "sets", Label: "knee raises", Sets: 4, RepsMin: 6, RepsMax: 12})
+		exercises = append(exercises, Item{Kind: "sets", Label: "knee raises", Sets: 4, RepsMin: 6, RepsMax: 12})
 	}
+	items = append(items, circuit(exercises)...)
 	items = append(items,
 		Item{Kind: "sets", Label: finisher, Sets: 1, RepsMin: 12, RepsMax: 20, Note: "finisher"},
 		Item{Kind: "check", Label: "stretch"},
@@ -88,3 +220,82 @@ func strengthB(finisher string, includeKneeRaises bool) []Item {
 	)
 	return items
 }
+
+// Mesocycle is a training block: BuildWeeks at the planned volume, then
+// DeloadWeeks in which sets and rep targets are scaled down.
+type Mesocycle struct {
+	BuildWeeks  int
+	DeloadWeeks int
+	DeloadSets  float64 // share of the planned sets (circuit rounds) kept
+	DeloadReps  float64 // share of the rep targets kept
+}
+
+// Block is the program's mesocycle.
+var Block = Mesocycle{BuildWeeks: 3, DeloadWeeks: 1, DeloadSets: 0.5, DeloadReps: 0.75}
+
+// Phase is where a week falls in its block.
+type Phase struct {
+	Block  int  // from 1
+	Week   int  // week of the phase, from 1
+	Weeks  int  // weeks in the phase
+	Deload bool // otherwise a build week
+}
+
+func (p Phase) String() string {
+	name := "Build"
+	if p.Deload {
+		name = "Deload"
+	}
+	return fmt.Sprintf("%s week %d of %d", name, p.Week, p.Weeks)
+}
+
+// PhaseOf places week (from 0, counted since the first block started) in
+// its block.
+func (m Mesocycle) PhaseOf(week int) Phase {
+	length := m.BuildWeeks + m.DeloadWeeks
+	if length <= 0 {
+		return Phase{Block: 1, Week: 1, Weeks: 1}
+	}
+	p := Phase{Block: week/length + 1}
+	if w := week % length; w < m.BuildWeeks {
+		p.Week, p.Weeks = w+1, m.BuildWeeks
+	} else {
+		p.Week, p.Weeks, p.Deload = w-m.BuildWeeks+1, m.DeloadWeeks, true
+	}
+	return p
+}
+
+// DayIn is Day(n) as planned in phase p: deload weeks keep a share of the
+// sets, circuit rounds and rep targets, never less than one. Labels don't
+// change, so deload sessions log under the same names.
+func DayIn(n int, p Phase) []Item {
+	items := Day(n)
+	if !p.Deload {
+		return items
+	}
+	scale := func(v int, share float64) int {
+		if v <= 0 {
+			return v
+		}
+		return max(1, int(math.Round(float64(v)*share)))
+	}
+	rounds := 0
+	for i := range items {
+		it := &items[i]
+		switch {
+		case it.Kind == "heading" && it.Circuit:
+			it.RoundsMin = scale(it.RoundsMin, Block.DeloadSets)
+			it.RoundsMax = scale(it.RoundsMax, Block.DeloadSets)
+			rounds = it.RoundsMax // the label stays; it names the circuit in the log
+		case it.Kind == "sets":
+			if it.Circuit {
+				it.Sets = rounds
+			} else {
+				it.Sets = scale(it.Sets, Block.DeloadSets)
+			}
+			it.RepsMin = scale(it.RepsMin, Block.DeloadReps)
+			it.RepsMax = scale(it.RepsMax, Block.DeloadReps)
+		}
+	}
+	return items
+}
@@ -0,0 +1,109 @@
+package plan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPhaseOf(t *testing.T) {
+	m := Mesocycle{BuildWeeks: 3, DeloadWeeks: 1}
+	tests := []struct {
+		week int
+		want Phase
+	}{
+		{0, Phase{Block: 1, Week: 1, Weeks: 3}},
+		{2, Phase{Block: 1, Week: 3, Weeks: 3}},
+		{3, Phase{Block: 1, Week: 1, Weeks: 1, Deload: true}},
+		{4, Phase{Block: 2, Week: 1, Weeks: 3}},
+		{7, Phase{Block: 2, Week: 1, Weeks: 1, Deload: true}},
+		{41, Phase{Block: 11, Week: 2, Weeks: 3}},
+	}
+	for _, tt := range tests {
+		if got := m.PhaseOf(tt.week); got != tt.want {
+			t.Errorf("PhaseOf(%d) = %+v, want %+v", tt.week, got, tt.want)
+		}
+	}
+
+	two := Mesocycle{BuildWeeks: 4, DeloadWeeks: 2}
+	if got, want := two.PhaseOf(5), (Phase{Block: 1, Week: 2, Weeks: 2, Deload: true}); got != want {
+		t.Errorf("4+2 PhaseOf(5) = %+v, want %+v", got, want)
+	}
+	if got, want := (Mesocycle{}).PhaseOf(9), (Phase{Block: 1, Week: 1, Weeks: 1}); got != want {
+		t.Errorf("empty PhaseOf(9) = %+v, want %+v", got, want)
+	}
+	if got, want := m.PhaseOf(3).String(), "Deload week 1 of 1"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestDayInBuildIsDay(t *testing.T) {
+	for n := 1; n <= 12; n++ {
+		if got := DayIn(n, Phase{Block: 1, Week: 2, Weeks: 3}); !reflect.DeepEqual(got, Day(n)) {
+			t.Errorf("day %d: build week differs from Day", n)
+		}
+	}
+}
+
+func TestDayInDeload(t *testing.T) {
+	deload := Phase{Block: 1, Week: 1, Weeks: 1, Deload: true}
+	for n := 1; n <= 12; n++ {
+		full, light := Day(n), DayIn(n, deload)
+		if len(full) != len(light) {
+			t.Fatalf("day %d: %d items, deload has %d", n, len(full), len(light))
+		}
+		rounds := 0
+		for i, it := range light {
+			f := full[i]
+			if it.Label != f.Label || it.Kind != f.Kind {
+				t.Errorf("day %d item %d: %q (%s) became %q (%s)", n, i, f.Label, f.Kind, it.Label, it.Kind)
+			}
+			switch {
+			case it.Kind == "heading" && it.Circuit:
+				if it.RoundsMax < 1 || it.RoundsMax > f.RoundsMax || it.RoundsMin > it.RoundsMax {
+					t.Errorf("day %d %q: rounds %d-%d from %d-%d", n, it.Label, it.RoundsMin, it.RoundsMax, f.RoundsMin, f.RoundsMax)
+				}
+				rounds = it.RoundsMax
+			case it.Kind == "sets":
+				if it.Circuit && it.Sets != rounds {
+					t.Errorf("day %d %q: %d sets in a circuit of %d rounds", n, it.Label, it.Sets, rounds)
+				}
+				if it.Sets < 1 || it.Sets > f.Sets {
+					t.Errorf("day %d %q: %d sets from %d", n, it.Label, it.Sets, f.Sets)
+				}
+				if f.RepsMax > 0 && (it.RepsMax < 1 || it.RepsMax > f.RepsMax || it.RepsMin > it.RepsMax) {
+					t.Errorf("day %d %q: reps %d-%d from %d-%d", n, it.Label, it.RepsMin, it.RepsMax, f.RepsMin, f.RepsMax)
+				}
+			}
+		}
+	}
+}
+
+func TestDayInScales(t *testing.T) {
+	deload := Phase{Deload: true}
+	for n := 1; n <= 12; n++ {
+		full := Day(n)
+		for i, it := range DayIn(n, deload) {
+			f := full[i]
+			if it.Kind != "sets" || it.Circuit || f.Sets < 2 {
+				continue
+			}
+			// 4 sets of 8-15 become 2 sets of 6-11
+			if f.Sets == 4 && it.Sets != 2 {
+				t.Errorf("day %d %q: 4 sets became %d", n, it.Label, it.Sets)
+			}
+			if f.RepsMin == 8 && f.RepsMax == 15 && (it.RepsMin != 6 || it.RepsMax != 11) {
+				t.Errorf("day %d %q: 8-15 became %d-%d", n, it.Label, it.RepsMin, it.RepsMax)
+			}
+		}
+	}
+}
+
+func TestDayInLeavesDayAlone(t *testing.T) {
+	for n := 1; n <= 12; n++ {
+		before := Day(n)
+		DayIn(n, Phase{Deload: true})
+		if !reflect.DeepEqual(before, Day(n)) {
+			t.Errorf("day %d: DayIn changed Day", n)
+		}
+	}
+}
@@ -10,7 +10,7 @@ STAMP="$(date -u +%Y%m%d%H%M%S)"
 PKG="traininglog-${STAMP}-${REV}"
 
 rm -rf .release
-mkdir -p ".release/${PKG}/web/templates" ".release/${PKG}/web/static/dist"
+mkdir -p ".release/${PKG}/web/templates" ".release/${PKG}/web/static/dist" ".release/${PKG}/web/static/js"
 
 # CSS (minified)
 ./tailwindcss -i web/static/src/input.css -o web/static/dist/app.css --minify
@@ -18,6 +18,7 @@ mkdir -p ".release/${PKG}/web/templates" ".release/${PKG}/web/static/dist"
 # Copy assets
 cp -r web/templates/* ".release/${PKG}/web/templates/"
 cp -r web/static/dist/* ".release/${PKG}/web/static/dist/"
+cp -r web/static/js/* ".release/${PKG}/web/static/js/"
 
 # Build Linux binary
 CGO_ENABLED=0 GOOS="$GOOS" GOARCH="$GOARCH" go build -ldflags="-s -w" -o ".release/${PKG}/traininglog" ./cmd/server
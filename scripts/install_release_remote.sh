@@ -10,6 +10,15 @@ scp "$PKG" "${SSH_HOST}:/tmp/"
 # Install on droplet (systemd unit already exists; Apache already reverse-proxying)
 ssh "$SSH_HOST" bash -c "'
   set -euo pipefail
+  # Snapshot the database through the running app before touching anything.
+  # Best effort: a stopped or crashed service, or a release older than
+  # /admin/backup, must not block the redeploy that fixes it.
+  sudo mkdir -p /opt/traininglog/backups
+  SNAP=/opt/traininglog/backups/pre-${PKG%.tar.gz}.json
+  if ! curl -fsS http://127.0.0.1:8082/admin/backup | sudo tee \$SNAP >/dev/null; then
+    sudo rm -f \$SNAP
+    echo \"warning: pre-deploy backup skipped\" >&2
+  fi
   sudo systemctl stop traininglog
   sudo tar -C /opt/traininglog -xzf /tmp/${PKG}
   # Move into place (extract creates /opt/traininglog/<PKG>/...)
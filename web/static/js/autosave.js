@@ -0,0 +1,31 @@
+// Autosave for the session form.
+//
+// Inputs marked data-autosave PUT just their own value (hx-put in the
+// template) a moment after they change. The template can't know the
+// workout id before the first save, so it writes "_" in the path; we fill
+// in the workout id here, or the form's client UUID while there is none
+// yet (the server then creates the draft and sends the id back). The
+// exercise swap select is the exception: it posts the whole form so the
+// server can re-render its block as typed.
+(function () {
+  "use strict";
+
+  document.addEventListener("htmx:configRequest", (evt) => {
+    const el = evt.detail.elt;
+    if (!el.hasAttribute || !el.hasAttribute("data-autosave")) return;
+    const form = el.form;
+    if (!form) return;
+
+    const id = form.elements.workout_id.value;
+    const ref = id && id !== "0" ? id : form.elements.client_uuid.value;
+    evt.detail.path = evt.detail.path.replace("/sessions/_/", `/sessions/${encodeURIComponent(ref)}/`);
+
+    if (el.type === "checkbox") {
+      evt.detail.parameters["checked"] = el.checked ? "1" : "";
+    } else {
+      evt.detail.parameters["value"] = el.value;
+    }
+    // a set of a swapped-in exercise still belongs to its planned item
+    if (el.dataset.planned) evt.detail.parameters["planned"] = el.dataset.planned;
+  });
+})();
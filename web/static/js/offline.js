@@ -0,0 +1,211 @@
+// Offline logging for the session form.
+//
+// Every form carries a client-generated UUID (client_uuid). When a save or
+// complete can't reach the server, the form state is queued in localStorage
+// under that UUID (newer saves replace older ones) and replayed to
+// POST /sync/workouts/{uuid} once we're back online. The server answers 409
+// if the workout changed there since the revision we last saw; the banner
+// then lets the user overwrite it or drop the local copy.
+(function () {
+  "use strict";
+
+  const QUEUE_KEY = "traininglog:sync-queue";
+
+  if ("serviceWorker" in navigator) {
+    navigator.serviceWorker.register("/sw.js").catch(() => {});
+  }
+
+  function csrfToken() {
+    const m = document.cookie.match(/(?:^|; )csrf_token=([^;]*)/);
+    return m ? m[1] : "";
+  }
+
+  function newUUID() {
+    if (crypto.randomUUID) return crypto.randomUUID();
+    const b = crypto.getRandomValues(new Uint8Array(16));
+    b[6] = (b[6] & 0x0f) | 0x40;
+    b[8] = (b[8] & 0x3f) | 0x80;
+    const h = Array.from(b, (x) => x.toString(16).padStart(2, "0")).join("");
+    return `${h.slice(0, 8)}-${h.slice(8, 12)}-${h.slice(12, 16)}-${h.slice(16, 20)}-${h.slice(20)}`;
+  }
+
+  function loadQueue() {
+    try {
+      return JSON.parse(localStorage.getItem(QUEUE_KEY)) || {};
+    } catch (e) {
+      return {};
+    }
+  }
+
+  function saveQueue(q) {
+    localStorage.setItem(QUEUE_KEY, JSON.stringify(q));
+  }
+
+  function enqueue(form, complete) {
+    const body = new URLSearchParams(new FormData(form));
+    const uuid = body.get("client_uuid");
+    if (!uuid) return false;
+    const q = loadQueue();
+    const prev = q[uuid];
+    q[uuid] = {
+      body: body.toString(),
+      base: Number(form.dataset.revision || 0),
+      complete: complete || Boolean(prev && prev.complete),
+      queuedAt: new Date().toISOString(),
+    };
+    saveQueue(q);
+    render();
+    return true;
+  }
+
+  let flushing = false;
+
+  async function flush() {
+    if (flushing || !navigator.onLine) return;
+    flushing = true;
+    try {
+      const q = loadQueue();
+      for (const [uuid, entry] of Object.entries(q)) {
+        if (entry.conflict && !entry.force) continue;
+        const body = new URLSearchParams(entry.body);
+        body.set("base_revision", String(entry.base));
+        if (entry.complete) body.set("complete", "1");
+        if (entry.force) body.set("force", "1");
+
+        let res;
+        try {
+          res = await fetch(`/sync/workouts/${uuid}`, {
+            method: "POST",
+            headers: { "X-CSRF-Token": csrfToken() },
+            body,
+          });
+        } catch (e) {
+          break; // still offline; try again later
+        }
+        const data = await res.json().catch(() => ({}));
+        if (res.ok) {
+          delete q[uuid];
+          synced(uuid, data);
+        } else if (res.status === 409) {
+          entry.conflict = data;
+          entry.force = false;
+        } else if (res.status === 422) {
+          entry.invalid = data.errors || {};
+        }
+        saveQueue(q);
+      }
+    } finally {
+      flushing = false;
+      render();
+    }
+  }
+
+  // synced points the open form (if it's the same session) at the server row.
+  function synced(uuid, data) {
+    const form = document.getElementById("sessionForm");
+    if (!form || form.elements.client_uuid.value !== uuid) return;
+    form.elements.workout_id.value = data.workout_id;
+    form.dataset.revision = data.revision;
+  }
+
+  function render() {
+    const el = document.getElementById("sync-status");
+    if (!el) return;
+    const entries = Object.entries(loadQueue());
+    el.replaceChildren();
+    if (entries.length === 0) return;
+
+    const head = document.createElement("div");
+    head.textContent = `${entries.length} session${entries.length === 1 ? "" : "s"} saved offline, waiting to sync.`;
+    el.appendChild(head);
+
+    for (const [uuid, entry] of entries) {
+      if (!entry.conflict && !entry.invalid) continue;
+      const row = document.createElement("div");
+      row.className = "mt-1 flex flex-wrap items-center gap-2";
+      const msg = document.createElement("span");
+      if (entry.invalid) {
+        msg.textContent = `Queued session rejected: ${Object.values(entry.invalid).join("; ")}`;
+      } else if (entry.conflict.conflict === "deleted") {
+        msg.textContent = `Session #${entry.conflict.workout_id} was deleted on the server.`;
+      } else {
+        msg.textContent = `Session #${entry.conflict.workout_id} changed on the server since you went offline.`;
+      }
+      row.appendChild(msg);
+      if (entry.conflict && entry.conflict.conflict === "changed") {
+        row.appendChild(button("Keep mine", () => {
+          const q = loadQueue();
+          q[uuid].force = true;
+          q[uuid].base = entry.conflict.revision;
+          saveQueue(q);
+          flush();
+        }));
+      }
+      row.appendChild(button("Discard mine", () => {
+        const q = loadQueue();
+        delete q[uuid];
+        saveQueue(q);
+        render();
+      }));
+      el.appendChild(row);
+    }
+  }
+
+  function button(text, onClick) {
+    const b = document.createElement("button");
+    b.type = "button";
+    b.className = "underline";
+    b.textContent = text;
+    b.addEventListener("click", onClick);
+    return b;
+  }
+
+  function isComplete(detail) {
+    return detail.requestConfig && detail.requestConfig.path === "/session/complete";
+  }
+
+  function queuedOffline(form, complete) {
+    if (!enqueue(form, complete)) return;
+    const out = document.getElementById("save_result");
+    if (out) {
+      out.textContent = complete
+        ? "Offline: completion queued, it will sync when you're back online."
+        : "Offline: saved on this device, it will sync when you're back online.";
+    }
+  }
+
+  document.addEventListener("DOMContentLoaded", () => {
+    const form = document.getElementById("sessionForm");
+    if (form && !form.elements.client_uuid.value) {
+      form.elements.client_uuid.value = newUUID();
+    }
+    render();
+    flush();
+  });
+
+  window.addEventListener("online", flush);
+
+  // Skip the network entirely when the browser knows it's offline...
+  document.addEventListener("htmx:beforeRequest", (evt) => {
+    const form = document.getElementById("sessionForm");
+    if (!form || navigator.onLine) return;
+    if (!form.contains(evt.detail.elt)) return;
+    evt.preventDefault();
+    queuedOffline(form, isComplete(evt.detail));
+  });
+
+  // ...and fall back to the queue when it thought it wasn't.
+  document.addEventListener("htmx:sendError", (evt) => {
+    const form = document.getElementById("sessionForm");
+    if (!form || !form.contains(evt.detail.elt)) return;
+    queuedOffline(form, isComplete(evt.detail));
+  });
+
+  document.addEventListener("htmx:afterRequest", (evt) => {
+    const form = document.getElementById("sessionForm");
+    const xhr = evt.detail.xhr;
+    if (!form || !xhr || !form.contains(evt.detail.elt)) return;
+    const rev = xhr.getResponseHeader("X-Revision");
+    if (rev) form.dataset.revision = rev;
+  });
+})();
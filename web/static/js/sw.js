@@ -0,0 +1,62 @@
+// Service worker: keeps the app shell and the session form available offline.
+// Pages are network-first (fresh data when we have signal), assets cache-first.
+// Writes are never cached here; offline.js queues them and replays via /sync.
+const CACHE = "traininglog-v4";
+const SHELL = [
+  "/",
+  "/session/new",
+  "/static/app.css",
+  "/js/offline.js",
+  "/js/autosave.js",
+  "/js/timer.js",
+  "https://cdnjs.cloudflare.com/ajax/libs/htmx/2.0.7/htmx.min.js",
+];
+
+self.addEventListener("install", (event) => {
+  event.waitUntil(
+    caches.open(CACHE).then((cache) =>
+      Promise.all(SHELL.map((url) => cache.add(url).catch(() => {})))
+    ).then(() => self.skipWaiting())
+  );
+});
+
+self.addEventListener("activate", (event) => {
+  event.waitUntil(
+    caches.keys()
+      .then((keys) => Promise.all(keys.filter((k) => k !== CACHE).map((k) => caches.delete(k))))
+      .then(() => self.clients.claim())
+  );
+});
+
+self.addEventListener("fetch", (event) => {
+  const req = event.request;
+  if (req.method !== "GET") return;
+  const url = new URL(req.url);
+
+  const isAsset = url.origin !== self.location.origin ||
+    url.pathname.startsWith("/static/") || url.pathname.startsWith("/js/");
+  if (isAsset) {
+    event.respondWith(
+      caches.match(req).then((hit) => hit || fetch(req).then((res) => {
+        if (res.ok) {
+          const copy = res.clone();
+          caches.open(CACHE).then((cache) => cache.put(req, copy));
+        }
+        return res;
+      }))
+    );
+    return;
+  }
+
+  if (req.mode === "navigate") {
+    event.respondWith(
+      fetch(req).then((res) => {
+        if (res.ok) {
+          const copy = res.clone();
+          caches.open(CACHE).then((cache) => cache.put(req, copy));
+        }
+        return res;
+      }).catch(() => caches.match(req).then((hit) => hit || caches.match("/")))
+    );
+  }
+});
@@ -0,0 +1,159 @@
+// Rest and EMOM timers for the session form.
+//
+// Entering a set value starts a countdown for the item's rest (data-rest on
+// its row). Circuit rounds (data-rest-when="full") only start resting once
+// every exercise of the round is logged. The rest ends when "Next set" is pressed or the next set value
+// is entered; its actual length is then posted to /sessions/{id}/rests.
+// Circuit headings carry an EMOM timer (data-emom seconds per slot) that
+// counts rounds and buzzes at the top of each slot.
+//
+// The session's Started field fills in with the first logged value and
+// Ended with "Mark complete", unless they were set by hand.
+(function () {
+  "use strict";
+
+  let rest = null; // {label, plannedLabel, set, planned, started, timer}
+
+  function fmt(secs) {
+    const s = Math.abs(secs);
+    return `${secs < 0 ? "+" : ""}${Math.floor(s / 60)}:${String(s % 60).padStart(2, "0")}`;
+  }
+
+  function buzz() {
+    if (navigator.vibrate) navigator.vibrate(200);
+  }
+
+  function workoutRef(form) {
+    const id = form.elements.workout_id.value;
+    return id && id !== "0" ? id : form.elements.client_uuid.value;
+  }
+
+  function panel() {
+    return document.getElementById("rest-timer");
+  }
+
+  function tick() {
+    const el = panel();
+    if (!rest || !el) return;
+    const left = rest.planned - Math.round((Date.now() - rest.started) / 1000);
+    el.querySelector("[data-rest-clock]").textContent = fmt(left);
+    el.querySelector("[data-rest-clock]").classList.toggle("text-amber-300", left < 0);
+    if (left <= 0 && !rest.buzzed) {
+      rest.buzzed = true;
+      buzz();
+    }
+  }
+
+  function startRest(row, input) {
+    const el = panel();
+    if (!el) return;
+    rest = {
+      form: input.form,
+      label: row.dataset.label,
+      // a circuit round rests under the circuit's label
+      plannedLabel: row.dataset.restWhen === "full" ? row.dataset.label : input.dataset.planned || row.dataset.label,
+      set: Number(input.dataset.set),
+      planned: Number(row.dataset.rest),
+      started: Date.now(),
+    };
+    el.querySelector("[data-rest-what]").textContent = `rest after ${rest.label} #${rest.set}`;
+    el.hidden = false;
+    tick();
+    rest.timer = setInterval(tick, 1000);
+  }
+
+  function finishRest() {
+    if (!rest) return;
+    const r = rest;
+    rest = null;
+    clearInterval(r.timer);
+    const el = panel();
+    if (el) el.hidden = true;
+
+    htmx.ajax("POST", `/sessions/${encodeURIComponent(workoutRef(r.form))}/rests`, {
+      source: r.form,
+      target: "#save_result",
+      swap: "none",
+      values: {
+        label: r.label,
+        planned: r.plannedLabel,
+        set_index: String(r.set),
+        planned_secs: String(r.planned),
+        actual_secs: String(Math.round((Date.now() - r.started) / 1000)),
+      },
+    });
+  }
+
+  // localStamp formats now for a datetime-local input.
+  function localStamp() {
+    const d = new Date();
+    const p = (n) => String(n).padStart(2, "0");
+    return `${d.getFullYear()}-${p(d.getMonth() + 1)}-${p(d.getDate())}T${p(d.getHours())}:${p(d.getMinutes())}`;
+  }
+
+  function stamp(form, which) {
+    const el = form.querySelector(`[data-clock="${which}"]`);
+    if (!el || el.value) return;
+    el.value = localStamp();
+    el.dispatchEvent(new Event("change", { bubbles: true }));
+  }
+
+  document.addEventListener("change", (evt) => {
+    const el = evt.target;
+    if (el.form && el.form.id === "sessionForm" && (el.matches("input[data-set]") || el.type === "checkbox")) {
+      stamp(el.form, "start");
+    }
+  });
+
+  // capture, so the value is in place before htmx builds the request
+  document.addEventListener("click", (evt) => {
+    const btn = evt.target.closest && evt.target.closest("[data-clock-end]");
+    const form = document.getElementById("sessionForm");
+    if (btn && form) stamp(form, "end");
+  }, true);
+
+  document.addEventListener("change", (evt) => {
+    const input = evt.target;
+    if (!input.matches || !input.matches("input[data-set]") || input.value === "") return;
+    const row = input.closest("[data-rest]");
+    if (!row) return;
+    if (row.dataset.restWhen === "full" &&
+        Array.from(row.querySelectorAll("input[data-set]")).some((i) => i.value === "")) return;
+    if (rest && rest.label === row.dataset.label && rest.set === Number(input.dataset.set)) return;
+    finishRest();
+    startRest(row, input);
+  });
+
+  document.addEventListener("click", (evt) => {
+    if (evt.target.closest("[data-rest-done]")) {
+      finishRest();
+      return;
+    }
+    const toggle = evt.target.closest("[data-emom-toggle]");
+    if (toggle) toggleEMOM(toggle.closest("[data-emom]"));
+  });
+
+  function toggleEMOM(box) {
+    const clock = box.querySelector("[data-emom-clock]");
+    if (box._emom) {
+      clearInterval(box._emom);
+      box._emom = null;
+      clock.textContent = "";
+      return;
+    }
+    const slot = Number(box.dataset.emom);
+    const started = Date.now();
+    let round = 1;
+    const draw = () => {
+      const elapsed = Math.floor((Date.now() - started) / 1000);
+      const now = Math.floor(elapsed / slot) + 1;
+      if (now !== round) {
+        round = now;
+        buzz();
+      }
+      clock.textContent = `round ${round} · ${fmt(slot - (elapsed % slot))}`;
+    };
+    draw();
+    box._emom = setInterval(draw, 1000);
+  }
+})();